@@ -0,0 +1,149 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manages the local cache of provider responses",
+		Long: heredoc.Doc(`
+			Chart data returned by providers is cached on disk, by default
+			in '$HOME/.wsb/cache', to save API calls. Charts made of closed
+			bars are kept for '--cache-ttl' and charts that may include the
+			current bar for '--cache-current-ttl'.
+			`),
+	}
+
+	cmd.AddCommand(newCacheClearCmd())
+	cmd.AddCommand(newCacheStatsCmd())
+	return cmd
+}
+
+func newCacheClearCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Removes all the cache entries",
+		RunE:  cacheClear,
+	}
+
+	flags := cmd.Flags()
+	addCacheDirFlags(flags)
+	return cmd
+}
+
+func newCacheStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Prints the number and size of the cache entries of each provider",
+		RunE:  cacheStats,
+	}
+
+	flags := cmd.Flags()
+	addCacheDirFlags(flags)
+	addCacheTTLFlags(flags)
+	return cmd
+}
+
+func addCacheDirFlags(flags *flag.FlagSet) {
+	flags.StringVar(&cfgFile, "config", "", "Config file")
+	flags.Bool("print-config", false, heredoc.Doc(`
+		Prints the configuration to stderr`))
+	flags.String("cache-dir", "", heredoc.Doc(`
+		Directory of the cache of provider responses. Defaults to '$HOME/.wsb/cache'`))
+}
+
+// addCacheFlags adds the flags of commands reading cached provider responses
+func addCacheFlags(flags *flag.FlagSet) {
+	flags.String("cache-dir", "", heredoc.Doc(`
+		Directory of the cache of provider responses. Defaults to '$HOME/.wsb/cache'`))
+	flags.Bool("no-cache", false, heredoc.Doc(`
+		Always fetch data from the provider and do not update the cache`))
+	addCacheTTLFlags(flags)
+}
+
+// addCacheTTLFlags adds the flags of the time to live of cache entries
+func addCacheTTLFlags(flags *flag.FlagSet) {
+	flags.Duration("cache-ttl", finance.DefaultCacheTTL, heredoc.Doc(`
+		Time to live of cached charts made of closed bars`))
+	flags.Duration("cache-current-ttl", finance.DefaultCacheCurrentTTL, heredoc.Doc(`
+		Time to live of cached charts that may include the current bar`))
+}
+
+func loadCache(cmd *cobra.Command) (*finance.Cache, error) {
+	printConfig, err := cmd.Flags().GetBool("print-config")
+	if err != nil {
+		return nil, err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, printConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading configuration: %s", err)
+	}
+	if configuration.CacheDir == "" {
+		return nil, fmt.Errorf("Cache directory is not set")
+	}
+	return finance.NewCache(configuration.CacheDir, configuration.CacheTTL, configuration.CacheCurrentTTL), nil
+}
+
+func cacheClear(cmd *cobra.Command, args []string) error {
+	cache, err := loadCache(cmd)
+	if err != nil {
+		return err
+	}
+	if err = cache.Clear(); err != nil {
+		return fmt.Errorf("Error clearing cache: %v", err)
+	}
+	return nil
+}
+
+func cacheStats(cmd *cobra.Command, args []string) error {
+	cache, err := loadCache(cmd)
+	if err != nil {
+		return err
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		return fmt.Errorf("Error reading cache: %v", err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Provider",
+		"Entries",
+		"Expired",
+		"Size",
+	})
+	for _, s := range stats {
+		table.Append([]string{
+			s.Provider,
+			fmt.Sprintf("%d", s.Entries),
+			fmt.Sprintf("%d", s.Expired),
+			fmt.Sprintf("%d", s.Bytes),
+		})
+	}
+	table.Render() // Send output
+	return nil
+}
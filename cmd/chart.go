@@ -15,16 +15,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sync"
 
 	"context"
 	"github.com/MakeNowJust/heredoc"
-	"github.com/olekukonko/tablewriter"
 	"github.com/regel/wsb/pkg/config"
 	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/finance/adjust"
 	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"time"
@@ -46,7 +48,9 @@ func newOhlcCmd() *cobra.Command {
 
 			Response includes:
 			* Prices: Open, High, Low, Close
+			* Close price adjusted for splits and dividends
 			* Volume
+			* Dividend and split events (json output)
 			`),
 		RunE: chart,
 	}
@@ -64,17 +68,6 @@ func parseDate(date string) (time.Time, error) {
 	return time.Parse(dateFormatLong, date)
 }
 
-func formatDate(t time.Time) string {
-	h := t.Hour()
-	m := t.Minute()
-	s := t.Second()
-	n := t.Nanosecond()
-	if h == 0 && m == 0 && s == 0 && n == 0 {
-		return t.In(time.UTC).Format(dateFormat)
-	}
-	return t.In(time.UTC).Format(dateFormatLong)
-}
-
 func addOhlcFlags(flags *flag.FlagSet) {
 	addCommonFlags(flags)
 	now := time.Now()
@@ -85,9 +78,21 @@ Start time of Ohlc time range. Format: 2006-01-02, or 2006-01-02T15:04:05`))
 End time of Ohlc time range. Format: 2006-01-02 or 2006-01-02T15:04:05`))
 	flags.String("interval", "1d", heredoc.Doc(`
                 Time interval range. Supported values: (1d, 5d, 1mo, 3mo, 6mo, 1y, 2y, 5y, 10y, ytd, max)`))
+	flags.StringP("output", "o", output.FormatTable, heredoc.Doc(`
+		Output format. Supported values: (table, csv, json, ndjson, parquet)`))
+	flags.String("output-dir", "", heredoc.Doc(`
+		Write the data of each ticker to '<dir>/<ticker>.<format>' instead of stdout.
+		Defaults to the csv format if the output format is table`))
+	flags.Bool("append", false, heredoc.Doc(`
+		Append rows newer than the last row of existing files in the output directory`))
+	flags.String("adjust", "", heredoc.Doc(`
+		Back-adjust prices and volumes for corporate actions. Supported values: (none, splits, all).
+		Data is printed as returned by the provider if empty`))
+	addCacheFlags(flags)
+	addFailOnFlags(flags)
 }
 
-func chart(cmd *cobra.Command, args []string) error {
+func chart(cmd *cobra.Command, args []string) (err error) {
 	var wg sync.WaitGroup
 	printConfig, err := cmd.Flags().GetBool("print-config")
 	if err != nil {
@@ -104,6 +109,7 @@ func chart(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("Error creating handler: %s", err)
 	}
+	defer closeHandler(handler, &err)
 	interval, err := cmd.Flags().GetString("interval")
 	if err != nil {
 		return err
@@ -124,6 +130,28 @@ func chart(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	policy, err := getFailOn(cmd)
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	writer, err := newChartWriter(cmd, format)
+	if err != nil {
+		return err
+	}
+	mode, err := cmd.Flags().GetString("adjust")
+	if err != nil {
+		return err
+	}
+	if mode != "" {
+		if !adjust.ValidMode(mode) {
+			return fmt.Errorf("Unknown adjustment '%s'", mode)
+		}
+		writer = &adjustWriter{ChartWriter: writer, mode: mode}
+	}
 	chartChan := make(chan *types.Chart)
 	handler.GetOhlcBatch(context, &wg, chartChan, configuration.Tickers, interval, from, to)
 	go func() {
@@ -131,32 +159,73 @@ func chart(cmd *cobra.Command, args []string) error {
 		close(chartChan)
 	}()
 
-	PrintOhlc(chartChan)
-	return nil
+	summary := output.NewSummary(len(configuration.Tickers))
+	err = PrintOhlc(chartChan, writer, summary)
+	return finishRun(summary, policy, err)
 }
 
-func PrintOhlc(chartChan chan *types.Chart) {
+func newChartWriter(cmd *cobra.Command, format string) (output.ChartWriter, error) {
+	dir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return output.NewChartWriter(format, os.Stdout)
+	}
+	appendOnly, err := cmd.Flags().GetBool("append")
+	if err != nil {
+		return nil, err
+	}
+	if format == output.FormatTable {
+		format = output.FormatCSV
+	}
+	mode := output.DirReplace
+	if appendOnly {
+		mode = output.DirAppend
+	}
+	return output.NewDirWriter(dir, format, mode)
+}
+
+// adjustWriter back-adjusts charts before writing them. Charts that
+// cannot be adjusted are reported as errors of their ticker.
+type adjustWriter struct {
+	output.ChartWriter
+	mode string
+}
+
+func (a *adjustWriter) Write(chart *types.Chart) error {
+	adjusted, err := adjust.Adjust(chart, a.mode)
+	if err != nil {
+		return types.NewTickerError(chart.Ticker, chart.Provider, err)
+	}
+	return a.ChartWriter.Write(adjusted)
+}
+
+// PrintOhlc writes the charts received from chartChan and records
+// the tickers fetched and their errors in the summary
+func PrintOhlc(chartChan chan *types.Chart, writer output.ChartWriter, summary *output.Summary) error {
+	var err error
 	for data := range chartChan {
-		history := tablewriter.NewWriter(os.Stdout)
-		history.SetHeader([]string{
-			"Date",
-			"Open",
-			"High",
-			"Low",
-			"Close",
-			"Volume",
-		})
-		for _, row := range data.Ohlc {
-			history.Append([]string{
-				formatDate(row.Timestamp),
-				fmt.Sprintf("%.02f", row.Open),
-				fmt.Sprintf("%.02f", row.High),
-				fmt.Sprintf("%.02f", row.Low),
-				fmt.Sprintf("%.02f", row.Close),
-				fmt.Sprintf("%d", row.Volume),
-			})
+		if err != nil {
+			// Drain the channel so that producers can exit
+			continue
+		}
+		if data.Err != nil {
+			summary.Fail(data.Err)
+			continue
 		}
-		history.SetCaption(true, fmt.Sprintf("History of '%s'.", data.Ticker))
-		history.Render() // Send output
+		err = writer.Write(data)
+		var tickerErr *types.TickerError
+		if errors.As(err, &tickerErr) {
+			// Keep writing the other tickers
+			summary.Fail(tickerErr)
+			err = nil
+			continue
+		}
+		summary.Done(data.Ticker, len(data.Ohlc))
+	}
+	if err != nil {
+		return err
 	}
+	return writer.Flush()
 }
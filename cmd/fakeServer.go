@@ -0,0 +1,110 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/regel/wsb/pkg/fakeserver"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func newFakeServerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fake-server",
+		Short: "Serves fake market data on provider compatible endpoints",
+		Long: heredoc.Doc(`
+			Serve fake market data on endpoints compatible with the Yahoo Finance,
+			IEX Cloud, CoinGecko, Stooq and Alpha Vantage APIs, to run wsb without
+			network access:
+			* /v8/finance/chart/{ticker} and /quote/{ticker}/holders (Yahoo Finance)
+			* /v1/stock/market/batch (IEX Cloud)
+			* /api/v3/coins/{id}/ohlc (CoinGecko)
+			* /q/d/l/ (Stooq)
+			* /query daily and FX daily time series (Alpha Vantage)
+
+			Bars are daily bars generated by a random walk of each ticker, or
+			read from the files of '--data' written by 'wsb chart --output-dir'.
+			Point the provider urls to the server, e.g.
+			'wsb chart --yahoo-finance-query-url http://localhost:8080'.
+			`),
+		RunE: fakeServer,
+	}
+
+	flags := cmd.Flags()
+	addFakeServerFlags(flags)
+	return cmd
+}
+
+func addFakeServerFlags(flags *flag.FlagSet) {
+	flags.String("listen", "localhost:8080", heredoc.Doc(`
+		Address the server listens on`))
+	flags.String("data", "", heredoc.Doc(`
+		Directory of ticker files '<ticker>.<format>'. Tickers without a file are unknown.
+		Bars are generated by a random walk if empty`))
+	flags.String("data-format", output.FormatCSV, heredoc.Doc(`
+		Format of ticker files. Supported values: (csv, json, ndjson, parquet)`))
+}
+
+func fakeServer(cmd *cobra.Command, args []string) error {
+	listen, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return err
+	}
+	dir, err := cmd.Flags().GetString("data")
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("data-format")
+	if err != nil {
+		return err
+	}
+	var data fakeserver.Data = fakeserver.NewRandomWalk()
+	if dir != "" {
+		if data, err = fakeserver.NewFileData(dir, format); err != nil {
+			return err
+		}
+	}
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           fakeserver.NewServer(data),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-c.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving fake market data on http://%s\n", listen)
+	if err = server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -20,21 +20,15 @@ import (
 
 	"context"
 	"github.com/MakeNowJust/heredoc"
-	"github.com/olekukonko/tablewriter"
 	"github.com/regel/wsb/pkg/config"
 	"github.com/regel/wsb/pkg/finance"
 	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"os"
 )
 
-type holdersData struct {
-	breakdown            *types.HoldersBreakdown
-	institutionalHolders *types.HoldersTable
-	fundHolders          *types.HoldersTable
-}
-
 func newHoldersCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "hold",
@@ -56,9 +50,12 @@ func newHoldersCmd() *cobra.Command {
 
 func addHoldersFlags(flags *flag.FlagSet) {
 	addCommonFlags(flags)
+	flags.StringP("output", "o", output.FormatTable, heredoc.Doc(`
+		Output format. Supported values: (table, csv, json, yaml)`))
+	addFailOnFlags(flags)
 }
 
-func holders(cmd *cobra.Command, args []string) error {
+func holders(cmd *cobra.Command, args []string) (err error) {
 	var wg sync.WaitGroup
 	printConfig, err := cmd.Flags().GetBool("print-config")
 	if err != nil {
@@ -75,21 +72,39 @@ func holders(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("Error creating handler: %s", err)
 	}
+	defer closeHandler(handler, &err)
+
+	policy, err := getFailOn(cmd)
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	writer, err := output.NewHoldersWriter(format, os.Stdout)
+	if err != nil {
+		return err
+	}
 
-	holdersChan := make(chan *holdersData)
+	holdersChan := make(chan *output.Holders)
 	for _, ticker := range configuration.Tickers {
 		wg.Add(1)
 		go func(t string) {
 			breakdown, institutionalHolders, fundHolders, err := handler.GetHolders(context, t)
 			if err != nil {
+				holdersChan <- &output.Holders{
+					Ticker: t,
+					Err:    types.NewTickerError(t, handler.ProviderOf(t), err),
+				}
 				wg.Done()
-				println(fmt.Sprintf("Error fetching '%s' data: %v", t, err))
 				return
 			}
-			hd := &holdersData{
-				breakdown:            breakdown,
-				institutionalHolders: institutionalHolders,
-				fundHolders:          fundHolders,
+			hd := &output.Holders{
+				Ticker:               t,
+				Breakdown:            breakdown,
+				InstitutionalHolders: institutionalHolders,
+				FundHolders:          fundHolders,
 			}
 			holdersChan <- hd
 			wg.Done()
@@ -100,68 +115,36 @@ func holders(cmd *cobra.Command, args []string) error {
 		close(holdersChan)
 	}()
 
-	PrintHolders(holdersChan)
-	return nil
+	summary := output.NewSummary(len(configuration.Tickers))
+	err = PrintHolders(holdersChan, writer, summary)
+	return finishRun(summary, policy, err)
 }
 
-func PrintHolders(holdersChan chan *holdersData) {
-	breakdownTable := tablewriter.NewWriter(os.Stdout)
-	breakdownTable.SetHeader([]string{"Name",
-		"% of Shares Held by All Insider",
-		"% of Shares Held by Institutions",
-		"% of Float Held by Institutions",
-		"Number of Institutions Holding Shares",
-	})
-	breakdownTable.SetCaption(true, "Major Holders Breakdown.")
+// PrintHolders writes the holders received from holdersChan and records
+// the tickers fetched and their errors in the summary
+func PrintHolders(holdersChan chan *output.Holders, writer output.HoldersWriter, summary *output.Summary) error {
+	var err error
 	for hd := range holdersChan {
-		breakdownTable.Append([]string{
-			hd.breakdown.Ticker,
-			fmt.Sprintf("%.02f", hd.breakdown.PctSharesHeldbyAllInsider),
-			fmt.Sprintf("%.02f", hd.breakdown.PctSharesHeldbyInstitutions),
-			fmt.Sprintf("%.02f", hd.breakdown.PctFloatHeldbyInstitutions),
-			fmt.Sprintf("%d", hd.breakdown.NumberofInstitutionsHoldingShares),
-		})
-
-		institutionalTable := tablewriter.NewWriter(os.Stdout)
-		institutionalTable.SetHeader([]string{
-			"Holder",
-			"Shares",
-			"Date Reported",
-			"% Out",
-			"Value",
-		})
-		for _, row := range hd.institutionalHolders.Rows {
-			institutionalTable.Append([]string{
-				row.Holder,
-				fmt.Sprintf("%d", row.Shares),
-				row.DateReported.Format("2006-01-02"),
-				fmt.Sprintf("%.02f", row.PctOut),
-				fmt.Sprintf("%d", row.Value),
-			})
+		if err != nil {
+			// Drain the channel so that producers can exit
+			continue
 		}
-		institutionalTable.SetCaption(true, fmt.Sprintf("Top Institutional Holders '%s'.", hd.institutionalHolders.Ticker))
-		institutionalTable.Render() // Send output
-
-		fundTable := tablewriter.NewWriter(os.Stdout)
-		fundTable.SetHeader([]string{
-			"Holder",
-			"Shares",
-			"Date Reported",
-			"% Out",
-			"Value",
-		})
-		for _, row := range hd.fundHolders.Rows {
-			fundTable.Append([]string{
-				row.Holder,
-				fmt.Sprintf("%d", row.Shares),
-				row.DateReported.Format("2006-01-02"),
-				fmt.Sprintf("%.02f", row.PctOut),
-				fmt.Sprintf("%d", row.Value),
-			})
+		if hd.Err != nil {
+			summary.Fail(hd.Err)
+			continue
+		}
+		rows := 0
+		if hd.InstitutionalHolders != nil {
+			rows += len(hd.InstitutionalHolders.Rows)
 		}
-		fundTable.SetCaption(true, fmt.Sprintf("Top Mutual Fund Holders '%s'.", hd.fundHolders.Ticker))
-		fundTable.Render() // Send output
+		if hd.FundHolders != nil {
+			rows += len(hd.FundHolders.Rows)
+		}
+		summary.Done(hd.Ticker, rows)
+		err = writer.Write(hd)
 	}
-	breakdownTable.Render() // Send output
-
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
 }
@@ -0,0 +1,133 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"context"
+	"github.com/MakeNowJust/heredoc"
+	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+var errNoQuote = errors.New("no quote")
+
+func newQuoteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quote",
+		Short: "Prints a table of the latest quotes to the current shell",
+		Long: heredoc.Doc(`
+			Query the latest snapshot quote of selected tickers.
+			Tickers are batched in as few API calls as possible.
+
+			Response includes:
+			* Latest price
+			* Bid and Ask
+			* Change since the previous close
+			* Market state
+			`),
+		RunE: quote,
+	}
+
+	flags := cmd.Flags()
+	addQuoteFlags(flags)
+	return cmd
+}
+
+func addQuoteFlags(flags *flag.FlagSet) {
+	addCommonFlags(flags)
+	flags.StringP("output", "o", output.FormatTable, heredoc.Doc(`
+		Output format. Supported values: (table, csv, json)`))
+	addFailOnFlags(flags)
+}
+
+func quote(cmd *cobra.Command, args []string) (err error) {
+	printConfig, err := cmd.Flags().GetBool("print-config")
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, printConfig)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+
+	context := context.Background()
+	handler, err := finance.NewHandler(*configuration)
+	if err != nil {
+		return fmt.Errorf("Error creating handler: %s", err)
+	}
+	defer closeHandler(handler, &err)
+	policy, err := getFailOn(cmd)
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	writer, err := output.NewQuoteWriter(format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	summary := output.NewSummary(len(configuration.Tickers))
+	quotes, err := handler.GetQuotes(context, configuration.Tickers)
+	if err != nil {
+		for _, ticker := range configuration.Tickers {
+			summary.Fail(types.NewTickerError(ticker, handler.ProviderOf(ticker), err))
+		}
+		return finishRun(summary, policy, nil)
+	}
+	sorted, missing := sortQuotes(quotes, configuration.Tickers)
+	for _, q := range sorted {
+		summary.Done(q.Ticker, 1)
+	}
+	for _, ticker := range missing {
+		summary.Fail(types.NewTickerError(ticker, handler.ProviderOf(ticker), errNoQuote))
+	}
+	return finishRun(summary, policy, writer.Write(sorted))
+}
+
+// sortQuotes returns the quotes in the order of the selected tickers,
+// and the tickers without any quote. Providers may return symbols in
+// another case than the selected tickers, e.g. 'AAPL' for 'aapl'.
+// Quotes of qualified tickers, e.g. 'coinbase:BTC-USD', keep the qualifier.
+func sortQuotes(quotes []types.Quote, tickers []string) ([]types.Quote, []string) {
+	out := make([]types.Quote, 0, len(quotes))
+	missing := make([]string, 0)
+	for _, ticker := range tickers {
+		found := false
+		for _, q := range quotes {
+			if strings.EqualFold(q.Ticker, ticker) {
+				out = append(out, q)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, ticker)
+		}
+	}
+	return out, missing
+}
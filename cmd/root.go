@@ -25,11 +25,17 @@ import (
 )
 
 const (
-	defaultProvider          = "yahoo"
-	defaultYahooBaseUrl      = "https://finance.yahoo.com"
-	defaultYahooQueryUrl     = "https://query2.finance.yahoo.com"
-	defaultIexCloudQueryUrl  = "https://cloud.iexapis.com" // See https://iexcloud.io/docs/api
-	defaultCoingeckoQueryUrl = "https://api.coingecko.com"
+	defaultProvider             = "yahoo"
+	defaultYahooBaseUrl         = "https://finance.yahoo.com"
+	defaultYahooQueryUrl        = "https://query2.finance.yahoo.com"
+	defaultIexCloudQueryUrl     = "https://cloud.iexapis.com" // See https://iexcloud.io/docs/api
+	defaultCoingeckoQueryUrl    = "https://api.coingecko.com"
+	defaultStooqQueryUrl        = "https://stooq.com"
+	defaultAlphaVantageQueryUrl = "https://www.alphavantage.co"
+	defaultPolygonQueryUrl      = "https://api.polygon.io"
+	defaultBinanceQueryUrl      = "https://api.binance.com"
+	defaultCoinbaseQueryUrl     = "https://api.exchange.coinbase.com"
+	defaultKrakenQueryUrl       = "https://api.kraken.com"
 )
 
 var (
@@ -43,6 +49,7 @@ func NewRootCmd() *cobra.Command {
 		Long: heredoc.Doc(`
 			Get finance data
 			* Price history
+			* Latest quotes
 			* holder information
 			for the given ticker names.`),
 		SilenceUsage: true,
@@ -50,6 +57,12 @@ func NewRootCmd() *cobra.Command {
 
 	cmd.AddCommand(newHoldersCmd())
 	cmd.AddCommand(newOhlcCmd())
+	cmd.AddCommand(newSyncCmd())
+	cmd.AddCommand(newQuoteCmd())
+	cmd.AddCommand(newWatchCmd())
+	cmd.AddCommand(newSearchCmd())
+	cmd.AddCommand(newCacheCmd())
+	cmd.AddCommand(newFakeServerCmd())
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newGenerateDocsCmd())
 
@@ -67,7 +80,9 @@ func Execute() {
 func addCommonFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&cfgFile, "config", "", "Config file")
 	flags.String("provider", defaultProvider, heredoc.Doc(`
-                Provider of market data. Supported providers: 'yahoo' (default), 'iex', 'coingecko'`))
+                Provider of market data. Supported providers: 'yahoo' (default), 'iex', 'coingecko', 'stooq', 'alphavantage', 'polygon', 'binance',
+                'coinbase', 'kraken'.
+                A comma-separated list of providers, e.g. 'yahoo,iex', is tried in order to fetch charts`))
 	flags.String("yahoo-finance-url", defaultYahooBaseUrl, heredoc.Doc(`
 		Yahoo Finance Base Url`))
 	flags.String("yahoo-finance-query-url", defaultYahooQueryUrl, heredoc.Doc(`
@@ -80,15 +95,101 @@ func addCommonFlags(flags *pflag.FlagSet) {
 		The Most Comprehensive Cryptocurrency API`))
 	flags.String("coingecko-secret-token", "", heredoc.Doc(`
 		Secret token to enable access to the Paid API`))
+	flags.String("stooq-query-url", defaultStooqQueryUrl, heredoc.Doc(`
+		Stooq free daily, weekly and monthly price history`))
+	flags.String("alphavantage-query-url", defaultAlphaVantageQueryUrl, heredoc.Doc(`
+		Alpha Vantage stock, intraday and FX time series`))
+	flags.String("alphavantage-secret-token", "", heredoc.Doc(`
+		API key to enable access to Alpha Vantage API`))
+	flags.String("polygon-query-url", defaultPolygonQueryUrl, heredoc.Doc(`
+		Polygon.io stock aggregates`))
+	flags.String("polygon-secret-token", "", heredoc.Doc(`
+		API key to enable access to Polygon.io API`))
+	flags.Bool("polygon-unadjusted", false, heredoc.Doc(`
+		Fetch Polygon.io prices and volume not adjusted for splits`))
+	flags.String("binance-query-url", defaultBinanceQueryUrl, heredoc.Doc(`
+		Binance spot market klines of crypto pairs`))
+	flags.String("coinbase-query-url", defaultCoinbaseQueryUrl, heredoc.Doc(`
+		Coinbase Exchange candles of crypto pairs`))
+	flags.String("kraken-query-url", defaultKrakenQueryUrl, heredoc.Doc(`
+		Kraken candles of crypto pairs`))
 	flags.StringSlice("tickers", []string{}, heredoc.Doc(`
 		Names of selected tickers`))
 	flags.Bool("print-config", false, heredoc.Doc(`
 		Prints the configuration to stderr`))
 	flags.Bool("debug", false, heredoc.Doc(`
-		Print API calls to external tools to stdout`))
+		Print API calls to external tools to stderr with their status, latency and response size,
+		and the errors writing to the cache. Secret tokens are redacted`))
+	flags.String("debug-dir", "", heredoc.Doc(`
+		Directory where the responses of API calls are dumped if --debug is set`))
+	flags.String("record", "", heredoc.Doc(`
+		Directory where API calls and their responses are recorded as cassette files.
+		The cache is disabled while recording. Pin --from and --to to replay charts later`))
+	flags.String("replay", "", heredoc.Doc(`
+		Directory of cassette files replayed instead of calling providers.
+		API calls without a cassette fail, e.g. charts whose --from or --to differ from the recording`))
 	flags.Duration("dial-timeout", 5*time.Second, heredoc.Doc(`
 		Dial timeout to connect to external API sources`))
+	flags.Duration("request-timeout", 5*time.Second, heredoc.Doc(`
+		Timeout of each API call, including the download of the response.
+		Increase it to download long price histories on slow networks`))
+	flags.String("proxy", "", heredoc.Doc(`
+		Url of the HTTP(S) proxy, e.g. http://proxy.example.com:3128.
+		The HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used if empty`))
+	flags.String("ca-cert", "", heredoc.Doc(`
+		File of PEM encoded CA certificates trusted in addition to the system CAs`))
+	flags.String("client-cert", "", heredoc.Doc(`
+		File of the PEM encoded client certificate sent to external API sources`))
+	flags.String("client-key", "", heredoc.Doc(`
+		File of the PEM encoded private key of the client certificate`))
+	flags.String("user-agent", "wsb/"+Version, heredoc.Doc(`
+		User-Agent header of API calls`))
 	flags.Int("bursts", 1, heredoc.Doc(`
 		Permits bursts of at most N concurrent API calls`))
+	flags.String("yahoo-rate-limit", "2000/h", heredoc.Doc(`
+		Rate limit of Yahoo Finance API calls. Format: <calls>/<period>, e.g. 2000/h, 500/m or 10/30s`))
+	flags.Int("yahoo-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Yahoo Finance API calls. Defaults to --bursts`))
+	flags.String("iex-cloud-rate-limit", "100/s", heredoc.Doc(`
+		Rate limit of IEX Cloud API calls. Format: <calls>/<period>`))
+	flags.Int("iex-cloud-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent IEX Cloud API calls. Defaults to --bursts`))
+	flags.String("coingecko-rate-limit", "50/m", heredoc.Doc(`
+		Rate limit of CoinGecko API calls. Format: <calls>/<period>`))
+	flags.Int("coingecko-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent CoinGecko API calls. Defaults to --bursts`))
+	flags.String("stooq-rate-limit", "1/s", heredoc.Doc(`
+		Rate limit of Stooq API calls. Format: <calls>/<period>`))
+	flags.Int("stooq-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Stooq API calls. Defaults to --bursts`))
+	flags.String("alphavantage-rate-limit", "5/m", heredoc.Doc(`
+		Rate limit of Alpha Vantage API calls. Format: <calls>/<period>`))
+	flags.Int("alphavantage-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Alpha Vantage API calls. Defaults to --bursts`))
+	flags.String("polygon-rate-limit", "5/m", heredoc.Doc(`
+		Rate limit of Polygon.io API calls. Format: <calls>/<period>. Paid plans are unlimited, e.g. 100/s`))
+	flags.Int("polygon-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Polygon.io API calls. Defaults to --bursts`))
+	flags.String("binance-rate-limit", "1200/m", heredoc.Doc(`
+		Rate limit of Binance API calls. Format: <calls>/<period>`))
+	flags.Int("binance-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Binance API calls. Defaults to --bursts`))
+	flags.String("coinbase-rate-limit", "10/s", heredoc.Doc(`
+		Rate limit of Coinbase API calls. Format: <calls>/<period>`))
+	flags.Int("coinbase-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Coinbase API calls. Defaults to --bursts`))
+	flags.String("kraken-rate-limit", "1/s", heredoc.Doc(`
+		Rate limit of Kraken API calls. Format: <calls>/<period>`))
+	flags.Int("kraken-bursts", 0, heredoc.Doc(`
+		Permits bursts of at most N concurrent Kraken API calls. Defaults to --bursts`))
+	flags.String("rate-limit-state", "", heredoc.Doc(`
+		File keeping the rate limit budget of providers between consecutive runs.
+		Each run starts with a full budget if empty`))
+	flags.Int("max-retries", 3, heredoc.Doc(`
+		Maximum number of retries of API calls failing with a network error,
+		a '429 Too Many Requests' or a 5xx server error`))
+	flags.Duration("retry-max-wait", 30*time.Second, heredoc.Doc(`
+		Maximum wait before retrying an API call. Calls are not retried if the
+		provider asks to wait longer with the 'Retry-After' header`))
 
 }
@@ -0,0 +1,91 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"context"
+	"github.com/MakeNowJust/heredoc"
+	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func newSearchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Prints a table of ticker symbols matching a query to the current shell",
+		Long: heredoc.Doc(`
+			Search the ticker symbols of the provider by name or symbol.
+			The Id column is the ticker name to use with other commands,
+			e.g. 'avalanche-2' for the Avalanche coin on CoinGecko.
+
+			Response includes:
+			* Symbol and name
+			* Exchange and type
+			* Provider-specific id
+			`),
+		Args: cobra.MinimumNArgs(1),
+		RunE: search,
+	}
+
+	flags := cmd.Flags()
+	addSearchFlags(flags)
+	return cmd
+}
+
+func addSearchFlags(flags *flag.FlagSet) {
+	addCommonFlags(flags)
+	flags.StringP("output", "o", output.FormatTable, heredoc.Doc(`
+		Output format. Supported values: (table, csv, json)`))
+}
+
+func search(cmd *cobra.Command, args []string) (err error) {
+	printConfig, err := cmd.Flags().GetBool("print-config")
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, printConfig)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+
+	context := context.Background()
+	handler, err := finance.NewHandler(*configuration)
+	if err != nil {
+		return fmt.Errorf("Error creating handler: %s", err)
+	}
+	defer closeHandler(handler, &err)
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	writer, err := output.NewSymbolWriter(format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	symbols, err := handler.SearchSymbols(context, strings.Join(args, " "))
+	if err != nil {
+		return fmt.Errorf("Error searching symbols: %v", err)
+	}
+	return writer.Write(symbols)
+}
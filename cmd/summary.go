@@ -0,0 +1,71 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func addFailOnFlags(flags *flag.FlagSet) {
+	flags.String("fail-on", output.FailOnPartial, heredoc.Doc(`
+		Exit with a non-zero code when tickers fail. Supported values: (none, partial, any).
+		'partial' fails if the data of any ticker could not be fetched,
+		'any' also fails if the data of any ticker is empty`))
+}
+
+func getFailOn(cmd *cobra.Command) (string, error) {
+	policy, err := cmd.Flags().GetString("fail-on")
+	if err != nil {
+		return "", err
+	}
+	if !output.ValidFailOn(policy) {
+		return "", fmt.Errorf("Unknown fail-on policy '%s'", policy)
+	}
+	return policy, nil
+}
+
+// finishRun prints the summary of the run to stderr and returns the error
+// of the run, if any, or the error of the --fail-on policy
+func finishRun(summary *output.Summary, policy string, err error) error {
+	if werr := summary.Write(os.Stderr); werr != nil && err == nil {
+		err = werr
+	}
+	if err != nil {
+		return err
+	}
+	return summary.Check(policy)
+}
+
+// closeHandler saves the rate limit state of the handler. The error is
+// returned unless the run already failed, in which case it is printed.
+func closeHandler(handler *finance.Handler, err *error) {
+	cerr := handler.Close()
+	if cerr == nil {
+		return
+	}
+	cerr = fmt.Errorf("Error saving rate limit state: %v", cerr)
+	if *err == nil {
+		*err = cerr
+		return
+	}
+	println(cerr.Error())
+}
@@ -0,0 +1,282 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"context"
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mitchellh/go-homedir"
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/regel/wsb/pkg/store"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func newSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Updates a local store of stock price history (OHLC)",
+		Long: heredoc.Doc(`
+			Fetch the price history of selected tickers into a local store,
+			one file per provider, interval and ticker:
+			'<store>/<provider>/<interval>/<ticker>.<format>'
+
+			Only the bars since the last stored bar are fetched. The last
+			stored bar is fetched again and replaced, so that a bar that was
+			not closed during the previous sync is updated. The metadata of
+			each ticker and of its last fetch is stored in '<ticker>.<format>.meta.json'.
+			`),
+		RunE: syncStore,
+	}
+
+	flags := cmd.Flags()
+	addSyncFlags(flags)
+	return cmd
+}
+
+func addSyncFlags(flags *flag.FlagSet) {
+	addCommonFlags(flags)
+	flags.String("store", "", heredoc.Doc(`
+		Directory of the local store. Defaults to '$HOME/.wsb/store'`))
+	flags.String("from", "", heredoc.Doc(`
+		Start time of tickers that were never synced. Format: 2006-01-02, or 2006-01-02T15:04:05.
+		Defaults to one year before the end time`))
+	flags.String("to", "", heredoc.Doc(`
+		End time of the sync. Format: 2006-01-02 or 2006-01-02T15:04:05. Defaults to now`))
+	flags.String("interval", "1d", heredoc.Doc(`
+                Time interval range. Supported values: (1d, 5d, 1mo, 3mo, 6mo, 1y, 2y, 5y, 10y, ytd, max)`))
+	flags.StringP("output", "o", output.FormatParquet, heredoc.Doc(`
+		Format of the stored files. Supported values: (csv, json, ndjson, parquet)`))
+	addFailOnFlags(flags)
+}
+
+func syncStore(cmd *cobra.Command, args []string) (err error) {
+	var wg sync.WaitGroup
+	printConfig, err := cmd.Flags().GetBool("print-config")
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, printConfig)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+	// The store is always updated with fresh data
+	configuration.NoCache = true
+
+	context := context.Background()
+	handler, err := finance.NewHandler(*configuration)
+	if err != nil {
+		return fmt.Errorf("Error creating handler: %s", err)
+	}
+	defer closeHandler(handler, &err)
+	interval, err := cmd.Flags().GetString("interval")
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	toStr, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return err
+	}
+	if toStr != "" {
+		if to, err = parseDate(toStr); err != nil {
+			return err
+		}
+	}
+	from := to.AddDate(-1, 0, 0)
+	fromStr, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	if fromStr != "" {
+		if from, err = parseDate(fromStr); err != nil {
+			return err
+		}
+	}
+	dir, err := cmd.Flags().GetString("store")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(home, ".wsb", "store")
+	}
+	policy, err := getFailOn(cmd)
+	if err != nil {
+		return err
+	}
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	// Tickers start at the last bar stored by their first provider and
+	// tickers starting at the same time are fetched together
+	stores := &syncStores{dir: dir, interval: interval, format: format, stores: make(map[string]*store.Store)}
+	targets := make(map[string]syncTarget)
+	groups := make(map[time.Time][]string)
+	for _, ticker := range configuration.Tickers {
+		provider := handler.ProviderOf(ticker)
+		s, err := stores.get(provider)
+		if err != nil {
+			return err
+		}
+		_, symbol := finance.SplitTicker(ticker)
+		m, err := s.Metadata(symbol)
+		if err != nil {
+			return err
+		}
+		start := from
+		if m != nil && !m.Last.IsZero() {
+			start = m.Last
+		}
+		// Targets are keyed by the selected ticker so that a symbol
+		// qualified with different providers is stored in each store
+		targets[ticker] = syncTarget{provider: provider, start: start}
+		groups[start] = append(groups[start], ticker)
+	}
+
+	chartChan := make(chan *types.Chart)
+	for start, tickers := range groups {
+		handler.GetOhlcBatch(context, &wg, chartChan, tickers, interval, start, to)
+	}
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+
+	summary := output.NewSummary(len(configuration.Tickers))
+	err = PrintSync(chartChan, stores, targets, to, summary)
+	return finishRun(summary, policy, err)
+}
+
+// syncTarget is the first provider of a ticker and the start time of its sync
+type syncTarget struct {
+	provider string
+	start    time.Time
+}
+
+// syncStores opens the stores of the providers on first use
+type syncStores struct {
+	dir      string
+	interval string
+	format   string
+	stores   map[string]*store.Store
+}
+
+func (s *syncStores) get(provider string) (*store.Store, error) {
+	if st, ok := s.stores[provider]; ok {
+		return st, nil
+	}
+	st, err := store.NewStore(s.dir, provider, s.interval, s.format)
+	if err != nil {
+		return nil, err
+	}
+	s.stores[provider] = st
+	return st, nil
+}
+
+// PrintSync writes the charts to the store of the provider that served them
+// and prints a summary table
+func PrintSync(chartChan chan *types.Chart, stores *syncStores, targets map[string]syncTarget, to time.Time, summary *output.Summary) error {
+	var err error
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"Ticker",
+		"First",
+		"Last",
+		"Rows",
+		"Fetched From",
+		"Fetched Rows",
+	})
+	for data := range chartChan {
+		if err != nil {
+			// Drain the channel so that producers can exit
+			continue
+		}
+		if data.Err != nil {
+			summary.Fail(data.Err)
+			continue
+		}
+		summary.Done(data.Ticker, len(data.Ohlc))
+		target, ok := findTarget(targets, data.Ticker)
+		if !ok {
+			err = fmt.Errorf("Error storing '%s' data: unknown ticker", data.Ticker)
+			continue
+		}
+		// Bars served by a fallback provider are stored with that provider
+		provider := data.Provider
+		if provider == "" {
+			provider = target.provider
+		}
+		var s *store.Store
+		if s, err = stores.get(provider); err != nil {
+			continue
+		}
+		// Stores are per provider and keep the symbols without qualifier
+		chart := *data
+		_, chart.Ticker = finance.SplitTicker(data.Ticker)
+		var m *store.Metadata
+		m, err = s.Write(&chart, target.start, to)
+		if err != nil {
+			err = fmt.Errorf("Error storing '%s' data: %v", data.Ticker, err)
+			continue
+		}
+		table.Append([]string{
+			m.Ticker,
+			formatTime(m.First),
+			formatTime(m.Last),
+			fmt.Sprintf("%d", m.Rows),
+			formatTime(m.FetchedFrom),
+			fmt.Sprintf("%d", m.FetchedRows),
+		})
+	}
+	table.Render() // Send output
+	return err
+}
+
+// findTarget returns the target of the ticker. Providers may change the case of symbols.
+func findTarget(targets map[string]syncTarget, ticker string) (syncTarget, bool) {
+	if target, ok := targets[ticker]; ok {
+		return target, true
+	}
+	for symbol, target := range targets {
+		if strings.EqualFold(symbol, ticker) {
+			return target, true
+		}
+	}
+	return syncTarget{}, false
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(dateFormatLong)
+}
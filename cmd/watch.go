@@ -0,0 +1,187 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"context"
+	"github.com/MakeNowJust/heredoc"
+	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	watchSourceQuote = "quote"
+	watchSourceBar   = "bar"
+)
+
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Prints a table of prices refreshed in place to the current shell",
+		Long: heredoc.Doc(`
+			Poll the latest prices of selected tickers on a fixed interval
+			and redraw the table in place until interrupted with Ctrl-C.
+
+			Prices come from the latest quote, or from the latest OHLC bar
+			for providers that do not support quotes.
+			Up ticks are printed in green and down ticks in red.
+			`),
+		RunE: watch,
+	}
+
+	flags := cmd.Flags()
+	addWatchFlags(flags)
+	return cmd
+}
+
+func addWatchFlags(flags *flag.FlagSet) {
+	addCommonFlags(flags)
+	flags.Duration("refresh", 10*time.Second, heredoc.Doc(`
+		Time between two refreshes. API calls are throttled by the provider rate limit`))
+	flags.String("source", watchSourceQuote, heredoc.Doc(`
+		Source of prices. Supported values: (quote, bar)`))
+	flags.String("interval", "1d", heredoc.Doc(`
+		Time interval of OHLC bars if the source is 'bar'`))
+}
+
+func watch(cmd *cobra.Command, args []string) (err error) {
+	printConfig, err := cmd.Flags().GetBool("print-config")
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, printConfig)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+	// Bars are refreshed on every tick
+	configuration.NoCache = true
+
+	handler, err := finance.NewHandler(*configuration)
+	if err != nil {
+		return fmt.Errorf("Error creating handler: %s", err)
+	}
+	defer closeHandler(handler, &err)
+	refresh, err := cmd.Flags().GetDuration("refresh")
+	if err != nil {
+		return err
+	}
+	if refresh <= 0 {
+		return fmt.Errorf("Invalid refresh interval '%s'", refresh)
+	}
+	source, err := cmd.Flags().GetString("source")
+	if err != nil {
+		return err
+	}
+	interval, err := cmd.Flags().GetString("interval")
+	if err != nil {
+		return err
+	}
+	// Quotes are nil if none could be fetched
+	var poll func(c context.Context) ([]types.Quote, []error)
+	switch source {
+	case watchSourceQuote:
+		poll = func(c context.Context) ([]types.Quote, []error) {
+			quotes, err := handler.GetQuotes(c, configuration.Tickers)
+			if err != nil {
+				return nil, []error{fmt.Errorf("Error fetching data: %v", err)}
+			}
+			return quotes, nil
+		}
+	case watchSourceBar:
+		poll = func(c context.Context) ([]types.Quote, []error) {
+			return getBarQuotes(c, handler, configuration.Tickers, interval)
+		}
+	default:
+		return fmt.Errorf("Unknown source '%s'", source)
+	}
+
+	context, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	view := output.NewWatchView(os.Stdout)
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	var shown []types.Quote
+	for {
+		quotes, errs := poll(context)
+		if context.Err() != nil {
+			return nil
+		}
+		// The previous quotes are shown with the errors if none could be fetched.
+		// Tickers without any quote are left out of the view.
+		if quotes != nil {
+			shown, _ = sortQuotes(quotes, configuration.Tickers)
+		}
+		if err = view.Render(shown, errs, time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-context.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// getBarQuotes returns quotes built from the latest OHLC bar of each ticker
+// and the errors of the tickers that could not be fetched
+func getBarQuotes(c context.Context, handler *finance.Handler, tickers []string, interval string) ([]types.Quote, []error) {
+	quotes := make([]types.Quote, 0, len(tickers))
+	var errs []error
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	for _, ticker := range tickers {
+		points, err := handler.GetOhlc(c, ticker, interval, from, to)
+		if err != nil {
+			if c.Err() != nil {
+				return nil, []error{err}
+			}
+			errs = append(errs, fmt.Errorf("Error fetching '%s' data: %v", ticker, err))
+			continue
+		}
+		if len(points) == 0 {
+			continue
+		}
+		last := points[len(points)-1]
+		q := types.Quote{
+			Ticker:      ticker,
+			Timestamp:   last.Timestamp,
+			Price:       last.Close,
+			Open:        last.Open,
+			Volume:      last.Volume,
+			MarketState: types.MarketStateRegular,
+		}
+		if len(points) > 1 {
+			q.PreviousClose = points[len(points)-2].Close
+			q.Change = q.Price - q.PreviousClose
+			if q.PreviousClose != 0 {
+				q.ChangePercent = q.Change / q.PreviousClose * 100
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, errs
+}
@@ -0,0 +1,166 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Response headers not saved in cassettes
+var skippedHeaders = []string{"Set-Cookie", "Date"}
+
+// Cassette is a recorded API call. Cassettes are saved as
+// '<dir>/<host>/<key>.json' where the key is a hash of the method and
+// the url of the request, with secret tokens redacted.
+type Cassette struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type CassetteRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+}
+
+type CassetteResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// CassettePath returns the path of the cassette of the request
+func CassettePath(dir string, req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + RedactUrl(req.URL)))
+	return filepath.Join(dir, req.URL.Hostname(), hex.EncodeToString(sum[:])+".json")
+}
+
+// RecordTransport saves the responses of API calls as cassettes in Dir
+type RecordTransport struct {
+	Transport http.RoundTripper
+	Dir       string
+}
+
+// NewRecordTransport wraps the transport with a recorder of cassettes
+func NewRecordTransport(transport http.RoundTripper, dir string) *RecordTransport {
+	return &RecordTransport{
+		Transport: transport,
+		Dir:       dir,
+	}
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	header := res.Header.Clone()
+	for _, name := range skippedHeaders {
+		header.Del(name)
+	}
+	cassette := Cassette{
+		Request: CassetteRequest{
+			Method: req.Method,
+			Url:    RedactUrl(req.URL),
+		},
+		Response: CassetteResponse{
+			StatusCode: res.StatusCode,
+			Header:     header,
+			Body:       string(b),
+		},
+	}
+	if err = saveCassette(CassettePath(t.Dir, req), &cassette); err != nil {
+		return nil, fmt.Errorf("Error recording '%s': %v", cassette.Request.Url, err)
+	}
+	return res, nil
+}
+
+func saveCassette(path string, cassette *Cassette) error {
+	b, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ReplayTransport serves API calls from the cassettes of Dir. Calls
+// without a cassette fail.
+type ReplayTransport struct {
+	Dir string
+}
+
+// NewReplayTransport creates a transport replaying the cassettes of dir
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{
+		Dir: dir,
+	}
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	b, err := os.ReadFile(CassettePath(t.Dir, req))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No cassette of %s '%s' in '%s'", req.Method, RedactUrl(req.URL), t.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err = json.Unmarshal(b, &cassette); err != nil {
+		return nil, fmt.Errorf("Error reading cassette of '%s': %v", RedactUrl(req.URL), err)
+	}
+	header := cassette.Response.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cassette.Response.StatusCode, http.StatusText(cassette.Response.StatusCode)),
+		StatusCode:    cassette.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(cassette.Response.Body)),
+		ContentLength: int64(len(cassette.Response.Body)),
+		Request:       req,
+	}, nil
+}
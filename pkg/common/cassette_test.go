@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplayTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"Unknown symbol"}`)
+	}))
+	dir := t.TempDir()
+	url := ts.URL + "/quote?symbols=AAPL&token=secret"
+
+	recorder := &http.Client{Transport: NewRecordTransport(&http.Transport{}, dir)}
+	res, err := recorder.Get(url)
+	require.NoError(t, err)
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, `{"error":"Unknown symbol"}`, string(b), "Body must be the same")
+	ts.Close()
+
+	player := &http.Client{Transport: NewReplayTransport(dir)}
+	// Cassettes do not depend on secret tokens
+	res, err = player.Get(ts.URL + "/quote?symbols=AAPL&token=other")
+	require.NoError(t, err)
+	b, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode, "Status must be the same")
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"), "Content-Type must be the same")
+	require.Empty(t, res.Header.Get("Set-Cookie"), "Cookies must not be recorded")
+	require.Equal(t, `{"error":"Unknown symbol"}`, string(b), "Body must be the same")
+
+	_, err = player.Get(ts.URL + "/quote?symbols=GME")
+	require.Error(t, err)
+}
+
+func TestClientRecordAndReplay(t *testing.T) {
+	_, err := NewClient(ClientOptions{Record: t.TempDir(), Replay: t.TempDir()})
+	require.Error(t, err)
+}
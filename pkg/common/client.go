@@ -0,0 +1,185 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultDialTimeout is the timeout to connect to a provider
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultRequestTimeout is the timeout of each attempt of a request
+	DefaultRequestTimeout = 5 * time.Second
+)
+
+// ClientOptions configures the HTTP client used to call providers
+type ClientOptions struct {
+	// Timeout to connect to a provider. Defaults to DefaultDialTimeout.
+	DialTimeout time.Duration
+	// Timeout of each attempt of a request. Defaults to DefaultRequestTimeout.
+	RequestTimeout time.Duration
+	// Url of the HTTP(S) proxy. Proxy environment variables are used if empty.
+	Proxy string
+	// File of PEM encoded certificates of trusted CAs. System CAs are used if empty.
+	CACert string
+	// Files of the PEM encoded client certificate and key
+	ClientCert string
+	ClientKey  string
+	// User-Agent header of requests. Go default if empty.
+	UserAgent string
+	// Trace requests and responses to stderr
+	Debug bool
+	// Directory of response dumps if Debug is set
+	DebugDir string
+	// Directory where API calls are recorded as cassettes
+	Record string
+	// Directory of cassettes replayed instead of calling providers
+	Replay string
+	// Maximum number of retries of a request
+	MaxRetries int
+	// Maximum wait before a retry
+	RetryMaxWait time.Duration
+}
+
+// NewClient creates the HTTP client used to call providers
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	if opts.Record != "" && opts.Replay != "" {
+		return nil, fmt.Errorf("Cannot record and replay API calls at the same time")
+	}
+	dialTimeout := opts.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	requestTimeout := opts.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = DefaultRequestTimeout
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy url '%s'", opts.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	tlsConfig, err := newTLSConfig(opts.CACert, opts.ClientCert, opts.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout: dialTimeout,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: dialTimeout,
+	}
+	maxRetries := opts.MaxRetries
+	if opts.Record != "" {
+		transport = NewRecordTransport(transport, opts.Record)
+	}
+	if opts.Replay != "" {
+		transport = NewReplayTransport(opts.Replay)
+		// Replayed responses do not change
+		maxRetries = 0
+	}
+	if opts.Debug {
+		transport = NewDebugTransport(transport, os.Stderr, opts.DebugDir)
+	}
+	if opts.UserAgent != "" {
+		transport = &userAgentTransport{
+			Transport: transport,
+			UserAgent: opts.UserAgent,
+		}
+	}
+	return &http.Client{
+		Transport: NewRetryTransport(transport, maxRetries, opts.RetryMaxWait, requestTimeout),
+	}, nil
+}
+
+// WithLimiter returns a client sharing the transport of client whose
+// requests, retries included, wait for limiter. Clients which do not retry
+// are throttled once per request.
+func WithLimiter(client *http.Client, limiter *rate.Limiter) *http.Client {
+	out := *client
+	if retry, ok := client.Transport.(*RetryTransport); ok {
+		throttled := *retry
+		throttled.Limiter = limiter
+		out.Transport = &throttled
+	} else {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		out.Transport = &RetryTransport{Transport: transport, Limiter: limiter}
+	}
+	return &out
+}
+
+// newTLSConfig returns the TLS configuration with the custom CAs and client
+// certificate, or nil if none is configured
+func newTLSConfig(caCert string, clientCert string, clientKey string) (*tls.Config, error) {
+	if caCert == "" && clientCert == "" && clientKey == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA certificates: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No CA certificate found in '%s'", caCert)
+		}
+		config.RootCAs = pool
+	}
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("Both the client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// userAgentTransport sets the User-Agent header of requests
+type userAgentTransport struct {
+	Transport http.RoundTripper
+	UserAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.UserAgent)
+	return t.Transport.RoundTrip(req)
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientUserAgent(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientOptions{UserAgent: "wsb/test"})
+	require.NoError(t, err)
+	res, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, "wsb/test", userAgent, "User-Agent must be the same")
+}
+
+func TestClientProxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(ClientOptions{Proxy: proxy.URL})
+	require.NoError(t, err)
+	res, err := client.Get("http://finance.example.com/v8/finance/chart/AAPL")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, "http://finance.example.com/v8/finance/chart/AAPL", target, "Request must go through the proxy")
+
+	_, err = NewClient(ClientOptions{Proxy: "proxy.example.com"})
+	require.Error(t, err)
+}
+
+func TestClientCACert(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// The test server certificate is not trusted by default
+	client, err := NewClient(ClientOptions{})
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL)
+	require.Error(t, err)
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caCert, b, 0644))
+	client, err = NewClient(ClientOptions{CACert: caCert})
+	require.NoError(t, err)
+	res, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	_, err = NewClient(ClientOptions{CACert: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+	_, err = NewClient(ClientOptions{ClientCert: caCert})
+	require.Error(t, err, "Client key must be required")
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ClientOptions{RequestTimeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL)
+	require.Error(t, err)
+}
@@ -0,0 +1,137 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Query parameters redacted from traces
+var secretParams = []string{"token", "apikey", "apiKey", "api_key", "x_cg_pro_api_key"}
+
+// DebugTransport traces requests and responses. Each request is logged
+// with its status, latency and response size when the response body is
+// closed. Responses are also dumped to files of DumpDir if not empty.
+type DebugTransport struct {
+	Transport http.RoundTripper
+	// Writer of traces
+	Writer io.Writer
+	// Directory of response dumps. Responses are not dumped if empty.
+	DumpDir string
+
+	mu  sync.Mutex
+	seq int64
+}
+
+// NewDebugTransport wraps the transport with traces written to w
+func NewDebugTransport(transport http.RoundTripper, w io.Writer, dumpDir string) *DebugTransport {
+	return &DebugTransport{
+		Transport: transport,
+		Writer:    w,
+		DumpDir:   dumpDir,
+	}
+}
+
+// RedactUrl returns the url with the values of secret query parameters
+// replaced by 'REDACTED'
+func RedactUrl(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, name := range secretParams {
+		if _, ok := query[name]; ok {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	out := *u
+	out.RawQuery = query.Encode()
+	return out.String()
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	seq := atomic.AddInt64(&t.seq, 1)
+	target := RedactUrl(req.URL)
+	start := time.Now()
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		t.printf("[%d] %s %s error after %s: %v\n", seq, req.Method, target, time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	if t.DumpDir != "" {
+		if err := t.dump(seq, req, target, res); err != nil {
+			t.printf("[%d] Error dumping response: %v\n", seq, err)
+		}
+	}
+	res.Body = &tracedBody{
+		ReadCloser: res.Body,
+		done: func(size int64) {
+			t.printf("[%d] %s %s %d %s %d bytes\n", seq, req.Method, target, res.StatusCode, time.Since(start).Round(time.Millisecond), size)
+		},
+	}
+	return res, nil
+}
+
+// dump writes the response to '<seq>-<host>.txt' in the dump directory.
+// The body of the response is read and replaced by an in-memory copy.
+func (t *DebugTransport) dump(seq int64, req *http.Request, target string, res *http.Response) error {
+	b, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(t.DumpDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(t.DumpDir, fmt.Sprintf("%04d-%s.txt", seq, req.URL.Hostname()))
+	content := append([]byte(fmt.Sprintf("%s %s\n\n", req.Method, target)), b...)
+	return os.WriteFile(path, content, 0644)
+}
+
+func (t *DebugTransport) printf(format string, a ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.Writer, format, a...)
+}
+
+// tracedBody counts the bytes read from a response body
+type tracedBody struct {
+	io.ReadCloser
+	size int64
+	done func(size int64)
+	once sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.size) })
+	return err
+}
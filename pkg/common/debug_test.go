@@ -0,0 +1,62 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactUrl(t *testing.T) {
+	tests := map[string]string{
+		"https://cloud.iexapis.com/stable/stock/market/batch?symbols=AAPL&token=secret":           "https://cloud.iexapis.com/stable/stock/market/batch?symbols=AAPL&token=REDACTED",
+		"https://api.example.com/query?apikey=secret&symbol=IBM":                                  "https://api.example.com/query?apikey=REDACTED&symbol=IBM",
+		"https://query2.finance.yahoo.com/v8/finance/chart/AAPL?interval=1d":                      "https://query2.finance.yahoo.com/v8/finance/chart/AAPL?interval=1d",
+		"https://api.polygon.io/v2/aggs/grouped/locale/us/market/stocks/2021-03-04?apiKey=secret": "https://api.polygon.io/v2/aggs/grouped/locale/us/market/stocks/2021-03-04?apiKey=REDACTED",
+	}
+	for raw, expected := range tests {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		require.Equal(t, expected, RedactUrl(u), "Url must be the same")
+	}
+}
+
+func TestDebugTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"symbol":"AAPL"}`)
+	}))
+	defer ts.Close()
+
+	var traces bytes.Buffer
+	dir := t.TempDir()
+	client := &http.Client{
+		Transport: NewDebugTransport(&http.Transport{}, &traces, dir),
+	}
+	res, err := client.Get(ts.URL + "/quote?symbols=AAPL&token=secret")
+	require.NoError(t, err)
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, `{"symbol":"AAPL"}`, string(b), "Body must be the same")
+
+	trace := traces.String()
+	require.True(t, strings.HasPrefix(trace, "[1] GET "+ts.URL+"/quote?symbols=AAPL&token=REDACTED 200 "), trace)
+	require.True(t, strings.HasSuffix(trace, " 17 bytes\n"), trace)
+	require.NotContains(t, trace, "secret")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries), "Should contain one dump")
+	dump, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(dump), `{"symbol":"AAPL"}`)
+	require.NotContains(t, string(dump), "secret")
+}
@@ -16,12 +16,10 @@ package common
 
 import (
 	"context"
-	"fmt"
 	"golang.org/x/net/html"
 	"log"
 	"net/http"
 	"strings"
-	"time"
 )
 
 type Table struct {
@@ -33,16 +31,14 @@ func ReadHtml(c context.Context, client *http.Client, url string) ([]*Table, err
 	if err != nil {
 		log.Fatal(err)
 	}
-	ctx, cancel := context.WithTimeout(c, time.Duration(5*time.Second))
-	defer cancel()
-	req = req.WithContext(ctx)
+	req = req.WithContext(c)
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Non-OK HTTP status: %d", res.StatusCode)
+		return nil, &StatusError{StatusCode: res.StatusCode}
 	}
 
 	z := html.NewTokenizer(res.Body)
@@ -0,0 +1,203 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Initial wait before the first retry. The wait doubles on each retry.
+	retryBaseWait = 500 * time.Millisecond
+	// Maximum number of bytes of a retried response read to reuse the connection
+	maxDrainBytes = 4096
+)
+
+// RetryTransport retries idempotent requests that fail with a network
+// error, '429 Too Many Requests' or a 5xx server error. Retries wait for
+// the 'Retry-After' delay sent by the server, or for a jittered exponential
+// backoff, and are abandoned if the wait is longer than MaxWait.
+type RetryTransport struct {
+	Transport http.RoundTripper
+	// Maximum number of retries of a request
+	MaxRetries int
+	// Maximum wait before a retry
+	MaxWait time.Duration
+	// Timeout of each attempt. No timeout if zero.
+	Timeout time.Duration
+	// Rate limit of the provider. Each attempt, retries included, waits
+	// for the limiter. Not throttled if nil.
+	Limiter *rate.Limiter
+}
+
+// NewRetryTransport wraps the transport with retries
+func NewRetryTransport(transport http.RoundTripper, maxRetries int, maxWait time.Duration, timeout time.Duration) *RetryTransport {
+	return &RetryTransport{
+		Transport:  transport,
+		MaxRetries: maxRetries,
+		MaxWait:    maxWait,
+		Timeout:    timeout,
+	}
+}
+
+// IsRetryableStatus returns true if a request failing with the given status
+// code may succeed later
+func IsRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableError returns true for network errors and connections closed
+// in the middle of a response. Errors caused by the request context are fatal.
+func isRetryableError(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// retryAfter parses the 'Retry-After' header, in seconds or as an HTTP date
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// backoff returns a random wait between zero and the exponential backoff of the attempt
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	d := retryBaseWait << uint(attempt)
+	if d <= 0 || (t.MaxWait > 0 && d > t.MaxWait) {
+		d = t.MaxWait
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func (t *RetryTransport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+// cancelBody cancels the context of an attempt once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (t *RetryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	// The wait for the limiter is not part of the timeout of the attempt
+	if t.Limiter != nil {
+		if err := t.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if t.Timeout <= 0 {
+		return t.transport().RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	res, err := t.transport().RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	for attempt := 0; ; attempt++ {
+		res, err := t.roundTrip(req)
+		if !idempotent || attempt >= t.MaxRetries {
+			return res, err
+		}
+		var wait time.Duration
+		if err != nil {
+			if !isRetryableError(req, err) {
+				return nil, err
+			}
+			wait = t.backoff(attempt)
+		} else {
+			if !IsRetryableStatus(res.StatusCode) {
+				return res, nil
+			}
+			var ok bool
+			if wait, ok = retryAfter(res, time.Now()); ok {
+				if t.MaxWait > 0 && wait > t.MaxWait {
+					// The server asks to wait longer than permitted
+					return res, nil
+				}
+			} else {
+				wait = t.backoff(attempt)
+			}
+			io.CopyN(io.Discard, res.Body, maxDrainBytes)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int
+		retryAfter string
+		maxRetries int
+		maxWait    time.Duration
+		expected   int
+		requests   int
+	}{
+		{"retry on server error", http.MethodGet, []int{503, 502, 200}, "", 3, time.Millisecond, 200, 3},
+		{"retry on too many requests", http.MethodGet, []int{429, 200}, "0", 3, time.Second, 200, 2},
+		{"retries exhausted", http.MethodGet, []int{500, 500, 500}, "", 2, time.Millisecond, 500, 3},
+		{"fatal status", http.MethodGet, []int{404, 200}, "", 3, time.Millisecond, 404, 1},
+		{"retry after too long", http.MethodGet, []int{429, 200}, "120", 3, time.Second, 429, 1},
+		{"non idempotent method", http.MethodPost, []int{503, 200}, "", 3, time.Millisecond, 503, 1},
+		{"no retries", http.MethodGet, []int{503, 200}, "", 0, time.Millisecond, 503, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+				fmt.Fprintln(w, status)
+			}))
+			defer ts.Close()
+
+			client := &http.Client{
+				Transport: NewRetryTransport(&http.Transport{}, tt.maxRetries, tt.maxWait, time.Second),
+			}
+			req, err := http.NewRequest(tt.method, ts.URL, nil)
+			require.NoError(t, err)
+			res, err := client.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expected, res.StatusCode, "Status must be the same")
+			require.Equal(t, fmt.Sprintf("%d", tt.expected), strings.TrimSpace(string(b)))
+			require.Equal(t, tt.requests, requests, "Number of requests must be the same")
+		})
+	}
+}
+
+func TestRetryTransportContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{
+		Transport: NewRetryTransport(&http.Transport{}, 3, time.Minute, time.Second),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req.WithContext(ctx))
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Wait must be interrupted by the request context")
+}
+
+func TestRetryTransportLimiter(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer ts.Close()
+
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 3)
+	client := WithLimiter(&http.Client{
+		Transport: NewRetryTransport(&http.Transport{}, 3, time.Second, time.Second),
+	}, limiter)
+	res, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, 3, requests, "Should retry twice")
+	require.InDelta(t, 0, limiter.Tokens(), 0.01, "Each attempt must wait for the limiter")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req.WithContext(ctx))
+	require.Error(t, err, "Requests must wait for the limiter")
+	require.Equal(t, 3, requests, "Throttled requests must not be sent")
+}
+
+func TestIsRetryableError(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	require.NoError(t, err)
+	require.True(t, isRetryableError(req, io.ErrUnexpectedEOF), "Truncated responses must be retried")
+	require.True(t, isRetryableError(req, context.DeadlineExceeded), "Timeouts must be retried")
+	require.False(t, isRetryableError(req, io.EOF), "EOF must not be retried")
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	res := &http.Response{Header: http.Header{}}
+	_, ok := retryAfter(res, now)
+	require.False(t, ok)
+
+	res.Header.Set("Retry-After", "30")
+	d, ok := retryAfter(res, now)
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, d)
+
+	res.Header.Set("Retry-After", now.Add(time.Minute).Format(http.TimeFormat))
+	d, ok = retryAfter(res, now)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, d)
+}
@@ -33,6 +33,7 @@ import (
 
 var (
 	homeDir, _            = homedir.Dir()
+	defaultCacheDir       = filepath.Join(homeDir, ".wsb", "cache")
 	configSearchLocations = []string{
 		".",
 		filepath.Join(homeDir, ".wsb"),
@@ -42,17 +43,63 @@ var (
 )
 
 type Configuration struct {
-	Provider             string        `mapstructure:"provider"`
-	YahooFinanceUrl      string        `mapstructure:"yahoo-finance-url"`
-	YahooFinanceQueryUrl string        `mapstructure:"yahoo-finance-query-url"`
-	IexCloudQueryUrl     string        `mapstructure:"iex-cloud-query-url"`
-	IexCloudSecretToken  string        `mapstructure:"iex-cloud-secret-token"`
-	CoingeckoQueryUrl    string        `mapstructure:"coingecko-query-url"`
-	CoingeckoSecretToken string        `mapstructure:"coingecko-secret-token"`
-	DialTimeout          time.Duration `mapstructure:"dial-timeout"`
-	Bursts               int           `mapstructure:"bursts"`
-	Tickers              []string      `mapstructure:"tickers"`
-	Debug                bool          `mapstructure:"debug"`
+	Provider                string        `mapstructure:"provider"`
+	YahooFinanceUrl         string        `mapstructure:"yahoo-finance-url"`
+	YahooFinanceQueryUrl    string        `mapstructure:"yahoo-finance-query-url"`
+	IexCloudQueryUrl        string        `mapstructure:"iex-cloud-query-url"`
+	IexCloudSecretToken     string        `mapstructure:"iex-cloud-secret-token"`
+	CoingeckoQueryUrl       string        `mapstructure:"coingecko-query-url"`
+	CoingeckoSecretToken    string        `mapstructure:"coingecko-secret-token"`
+	StooqQueryUrl           string        `mapstructure:"stooq-query-url"`
+	AlphaVantageQueryUrl    string        `mapstructure:"alphavantage-query-url"`
+	AlphaVantageSecretToken string        `mapstructure:"alphavantage-secret-token"`
+	PolygonQueryUrl         string        `mapstructure:"polygon-query-url"`
+	PolygonSecretToken      string        `mapstructure:"polygon-secret-token"`
+	PolygonUnadjusted       bool          `mapstructure:"polygon-unadjusted"`
+	BinanceQueryUrl         string        `mapstructure:"binance-query-url"`
+	CoinbaseQueryUrl        string        `mapstructure:"coinbase-query-url"`
+	KrakenQueryUrl          string        `mapstructure:"kraken-query-url"`
+	DialTimeout             time.Duration `mapstructure:"dial-timeout"`
+	RequestTimeout          time.Duration `mapstructure:"request-timeout"`
+	Proxy                   string        `mapstructure:"proxy"`
+	CACert                  string        `mapstructure:"ca-cert"`
+	ClientCert              string        `mapstructure:"client-cert"`
+	ClientKey               string        `mapstructure:"client-key"`
+	UserAgent               string        `mapstructure:"user-agent"`
+	Bursts                  int           `mapstructure:"bursts"`
+	YahooRateLimit          string        `mapstructure:"yahoo-rate-limit"`
+	YahooBursts             int           `mapstructure:"yahoo-bursts"`
+	IexCloudRateLimit       string        `mapstructure:"iex-cloud-rate-limit"`
+	IexCloudBursts          int           `mapstructure:"iex-cloud-bursts"`
+	CoingeckoRateLimit      string        `mapstructure:"coingecko-rate-limit"`
+	CoingeckoBursts         int           `mapstructure:"coingecko-bursts"`
+	StooqRateLimit          string        `mapstructure:"stooq-rate-limit"`
+	StooqBursts             int           `mapstructure:"stooq-bursts"`
+	AlphaVantageRateLimit   string        `mapstructure:"alphavantage-rate-limit"`
+	AlphaVantageBursts      int           `mapstructure:"alphavantage-bursts"`
+	PolygonRateLimit        string        `mapstructure:"polygon-rate-limit"`
+	PolygonBursts           int           `mapstructure:"polygon-bursts"`
+	BinanceRateLimit        string        `mapstructure:"binance-rate-limit"`
+	BinanceBursts           int           `mapstructure:"binance-bursts"`
+	CoinbaseRateLimit       string        `mapstructure:"coinbase-rate-limit"`
+	CoinbaseBursts          int           `mapstructure:"coinbase-bursts"`
+	KrakenRateLimit         string        `mapstructure:"kraken-rate-limit"`
+	KrakenBursts            int           `mapstructure:"kraken-bursts"`
+	RateLimitState          string        `mapstructure:"rate-limit-state"`
+	MaxRetries              int           `mapstructure:"max-retries"`
+	RetryMaxWait            time.Duration `mapstructure:"retry-max-wait"`
+	Tickers                 []string      `mapstructure:"tickers"`
+	// Providers of tickers, e.g. 'bitcoin: coingecko'. Tickers can also
+	// be qualified with their provider, e.g. 'coingecko:bitcoin'.
+	Routes          map[string]string `mapstructure:"routes"`
+	Debug           bool              `mapstructure:"debug"`
+	DebugDir        string            `mapstructure:"debug-dir"`
+	Record          string            `mapstructure:"record"`
+	Replay          string            `mapstructure:"replay"`
+	CacheDir        string            `mapstructure:"cache-dir"`
+	CacheTTL        time.Duration     `mapstructure:"cache-ttl"`
+	CacheCurrentTTL time.Duration     `mapstructure:"cache-current-ttl"`
+	NoCache         bool              `mapstructure:"no-cache"`
 }
 
 func PrintDelimiterLineToWriter(w io.Writer, delimiterChar string) {
@@ -108,6 +155,9 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, printConfig bool) (*C
 	if err := v.Unmarshal(cfg); err != nil {
 		return nil, errors.Wrap(err, "Error unmarshaling configuration")
 	}
+	if cfg.CacheDir == "" && homeDir != "" {
+		cfg.CacheDir = defaultCacheDir
+	}
 
 	if printConfig {
 		printCfg(cfg)
@@ -0,0 +1,136 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakeserver
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+)
+
+// Data returns the daily bars served by the fake server
+type Data interface {
+	// Bars returns the daily bars of the ticker sorted by time, or
+	// false if the ticker is unknown. Timestamps are days at 00:00 UTC.
+	Bars(ticker string) ([]types.Ohlc, bool, error)
+}
+
+// RandomWalk generates daily bars following a random walk. Bars only depend
+// on the ticker so that consecutive requests return consistent data.
+type RandomWalk struct {
+	// First day of the bars
+	Start time.Time
+	// Daily volatility of prices
+	Volatility float64
+	// Returns the current time
+	now func() time.Time
+}
+
+// NewRandomWalk creates random walks starting on 2000-01-01 with a
+// daily volatility of 2%
+func NewRandomWalk() *RandomWalk {
+	return &RandomWalk{
+		Start:      time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Volatility: 0.02,
+		now:        time.Now,
+	}
+}
+
+func (d *RandomWalk) Bars(ticker string) ([]types.Ohlc, bool, error) {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToUpper(ticker)))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	now := d.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	bars := make([]types.Ohlc, 0, int(today.Sub(d.Start).Hours()/24)+1)
+	price := 10 + rnd.Float64()*190
+	for day := d.Start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		open := price
+		price = open * math.Exp(d.Volatility*rnd.NormFloat64())
+		high := math.Max(open, price) * (1 + d.Volatility*math.Abs(rnd.NormFloat64())/2)
+		low := math.Min(open, price) * (1 - d.Volatility*math.Abs(rnd.NormFloat64())/2)
+		bars = append(bars, types.Ohlc{
+			Ticker:    ticker,
+			Timestamp: day,
+			Open:      round(open),
+			High:      round(high),
+			Low:       round(low),
+			Close:     round(price),
+			AdjClose:  round(price),
+			Volume:    int64(1000000 + rnd.Intn(9000000)),
+		})
+	}
+	return bars, true, nil
+}
+
+// round rounds prices to the cent
+func round(price float64) float64 {
+	return math.Round(price*100) / 100
+}
+
+// FileData reads the bars of tickers from the files written by
+// 'wsb chart --output-dir', one file per ticker: '<dir>/<ticker>.<format>'
+type FileData struct {
+	reader *output.DirWriter
+}
+
+// NewFileData creates the data of the files of dir
+func NewFileData(dir string, format string) (*FileData, error) {
+	reader, err := output.NewDirWriter(dir, format, output.DirReplace)
+	if err != nil {
+		return nil, err
+	}
+	return &FileData{reader: reader}, nil
+}
+
+func (d *FileData) Bars(ticker string) ([]types.Ohlc, bool, error) {
+	path, err := d.reader.Path(ticker)
+	if err != nil {
+		return nil, false, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	records, err := d.reader.Read(ticker)
+	if err != nil {
+		return nil, false, err
+	}
+	bars := make([]types.Ohlc, 0, len(records.Ohlc))
+	for _, r := range records.Ohlc {
+		t, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			return nil, false, err
+		}
+		y, m, day := t.Date()
+		bars = append(bars, types.Ohlc{
+			Ticker:    ticker,
+			Timestamp: time.Date(y, m, day, 0, 0, 0, 0, time.UTC),
+			Open:      r.Open,
+			High:      r.High,
+			Low:       r.Low,
+			Close:     r.Close,
+			AdjClose:  r.AdjClose,
+			Volume:    r.Volume,
+		})
+	}
+	return bars, true, nil
+}
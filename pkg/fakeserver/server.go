@@ -0,0 +1,438 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakeserver serves fake market data on endpoints compatible with
+// the Yahoo Finance, IEX Cloud, CoinGecko, Stooq and Alpha Vantage APIs
+// used by wsb.
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+const (
+	yahooChartPath    = "/v8/finance/chart/"
+	yahooQuotePath    = "/quote/"
+	iexBatchPath      = "/v1/stock/market/batch"
+	coingeckoCoinPath = "/api/v3/coins/"
+	stooqPath         = "/q/d/l/"
+	alphaVantagePath  = "/query"
+)
+
+// Time zone and opening time of the stock exchange of fake stocks
+var exchange, _ = time.LoadLocation("America/New_York")
+
+const exchangeOpen = 9*time.Hour + 30*time.Minute
+
+// Server serves fake market data. Stocks are traded on weekdays and
+// cryptocurrencies every day. Each bar is a daily bar whatever the
+// requested interval.
+type Server struct {
+	data Data
+}
+
+// NewServer creates a server of the given data
+func NewServer(data Data) *Server {
+	return &Server{data: data}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, yahooChartPath):
+		s.yahooChart(w, r, strings.TrimPrefix(path, yahooChartPath))
+	case strings.HasPrefix(path, yahooQuotePath) && strings.HasSuffix(path, "/holders"):
+		s.yahooHolders(w, r, strings.TrimSuffix(strings.TrimPrefix(path, yahooQuotePath), "/holders"))
+	case path == iexBatchPath:
+		s.iexBatch(w, r)
+	case strings.HasPrefix(path, coingeckoCoinPath) && strings.HasSuffix(path, "/ohlc"):
+		s.coingeckoOhlc(w, r, strings.TrimSuffix(strings.TrimPrefix(path, coingeckoCoinPath), "/ohlc"))
+	case path == stooqPath:
+		s.stooqHistory(w, r)
+	case path == alphaVantagePath:
+		s.alphaVantageQuery(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// stockBars returns the bars of the ticker traded on weekdays
+func (s *Server) stockBars(ticker string) ([]types.Ohlc, bool, error) {
+	bars, ok, err := s.data.Bars(ticker)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	out := make([]types.Ohlc, 0, len(bars))
+	for _, bar := range bars {
+		if day := bar.Timestamp.Weekday(); day != time.Saturday && day != time.Sunday {
+			out = append(out, bar)
+		}
+	}
+	return out, true, nil
+}
+
+// openTime returns the time the exchange opens on the day of the bar
+func openTime(bar types.Ohlc) time.Time {
+	y, m, d := bar.Timestamp.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, exchange).Add(exchangeOpen)
+}
+
+// since returns the bars of the last days
+func since(bars []types.Ohlc, days int) []types.Ohlc {
+	from := time.Now().UTC().AddDate(0, 0, -days)
+	for i, bar := range bars {
+		if !bar.Timestamp.Before(from) {
+			return bars[i:]
+		}
+	}
+	return bars[len(bars):]
+}
+
+func (s *Server) yahooChart(w http.ResponseWriter, r *http.Request, ticker string) {
+	bars, ok, err := s.stockBars(ticker)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"chart": map[string]interface{}{
+				"result": nil,
+				"error": map[string]string{
+					"code":        "Not Found",
+					"description": "No data found, symbol may be delisted",
+				},
+			},
+		})
+		return
+	}
+	query := r.URL.Query()
+	period1, _ := strconv.ParseInt(query.Get("period1"), 10, 64)
+	period2, err := strconv.ParseInt(query.Get("period2"), 10, 64)
+	if err != nil {
+		period2 = time.Now().Unix()
+	}
+	timestamps := make([]int64, 0)
+	var open, high, low, closes, adjClose []float64
+	var volume []int64
+	for _, bar := range bars {
+		t := openTime(bar).Unix()
+		if t < period1 || t > period2 {
+			continue
+		}
+		timestamps = append(timestamps, t)
+		open = append(open, bar.Open)
+		high = append(high, bar.High)
+		low = append(low, bar.Low)
+		closes = append(closes, bar.Close)
+		adjClose = append(adjClose, bar.AdjClose)
+		volume = append(volume, bar.Volume)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"chart": map[string]interface{}{
+			"result": []interface{}{
+				map[string]interface{}{
+					"meta": map[string]interface{}{
+						"currency":             "USD",
+						"symbol":               ticker,
+						"exchangeName":         "NMS",
+						"instrumentType":       "EQUITY",
+						"exchangeTimezoneName": exchange.String(),
+						"dataGranularity":      "1d",
+					},
+					"timestamp": timestamps,
+					"events":    map[string]interface{}{},
+					"indicators": map[string]interface{}{
+						"quote": []interface{}{
+							map[string]interface{}{
+								"open":   open,
+								"high":   high,
+								"low":    low,
+								"close":  closes,
+								"volume": volume,
+							},
+						},
+						"adjclose": []interface{}{
+							map[string]interface{}{
+								"adjclose": adjClose,
+							},
+						},
+					},
+				},
+			},
+			"error": nil,
+		},
+	})
+}
+
+func (s *Server) yahooHolders(w http.ResponseWriter, r *http.Request, ticker string) {
+	_, ok, err := s.data.Bars(ticker)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToUpper(ticker)))
+	seed := int64(h.Sum32())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintln(w, "<html><body>")
+	fmt.Fprintln(w, "<table><tbody>")
+	fmt.Fprintf(w, "<tr><td>%.2f%%</td><td>%% of Shares Held by All Insider</td></tr>\n", float64(seed%500)/100)
+	fmt.Fprintf(w, "<tr><td>%.2f%%</td><td>%% of Shares Held by Institutions</td></tr>\n", 40+float64(seed%4000)/100)
+	fmt.Fprintf(w, "<tr><td>%.2f%%</td><td>%% of Float Held by Institutions</td></tr>\n", 45+float64(seed%4000)/100)
+	fmt.Fprintf(w, "<tr><td>%d</td><td>Number of Institutions Holding Shares</td></tr>\n", 100+seed%5000)
+	fmt.Fprintln(w, "</tbody></table>")
+	reported := time.Now().UTC().AddDate(0, -1, 0).Format("Jan 2, 2006")
+	for _, kind := range []string{"Institutional", "Fund"} {
+		fmt.Fprintln(w, "<table><thead>")
+		fmt.Fprintln(w, "<tr><th>Holder</th><th>Shares</th><th>Date Reported</th><th>% Out</th><th>Value</th></tr>")
+		fmt.Fprintln(w, "</thead><tbody>")
+		for i := int64(1); i <= 10; i++ {
+			shares := (seed%1000 + 1) * 100000 / i
+			fmt.Fprintf(w, "<tr><td>%s Holder %d</td><td>%s</td><td>%s</td><td>%.2f%%</td><td>%s</td></tr>\n",
+				kind, i, formatInt(shares), reported, 10/float64(i), formatInt(shares*100))
+		}
+		fmt.Fprintln(w, "</tbody></table>")
+	}
+	fmt.Fprintln(w, "</body></html>")
+}
+
+// iexRange returns the number of days of an IEX Cloud range, e.g. '5d',
+// '3m', '1y' or 'max'
+func iexRange(value string) (int, error) {
+	if value == "max" {
+		return 365 * 100, nil
+	}
+	if len(value) < 2 {
+		return 0, fmt.Errorf("Invalid range '%s'", value)
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil {
+		return 0, fmt.Errorf("Invalid range '%s'", value)
+	}
+	switch value[len(value)-1] {
+	case 'd':
+		return n, nil
+	case 'm':
+		return n * 31, nil
+	case 'y':
+		return n * 366, nil
+	default:
+		return 0, fmt.Errorf("Invalid range '%s'", value)
+	}
+}
+
+func (s *Server) iexBatch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days, err := iexRange(query.Get("range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	type point struct {
+		Date   string  `json:"date"`
+		Open   float64 `json:"open"`
+		High   float64 `json:"high"`
+		Low    float64 `json:"low"`
+		Close  float64 `json:"close"`
+		FClose float64 `json:"fClose"`
+		Volume int64   `json:"volume"`
+	}
+	response := make(map[string]map[string][]point)
+	for _, ticker := range strings.Split(query.Get("symbols"), ",") {
+		if ticker == "" {
+			continue
+		}
+		bars, ok, err := s.stockBars(ticker)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		// Unknown symbols are left out of the response
+		if !ok {
+			continue
+		}
+		if days == 1 && len(bars) > 0 {
+			bars = bars[len(bars)-1:]
+		} else {
+			bars = since(bars, days)
+		}
+		chart := make([]point, 0, len(bars))
+		for _, bar := range bars {
+			chart = append(chart, point{
+				Date:   bar.Timestamp.Format("2006-01-02"),
+				Open:   bar.Open,
+				High:   bar.High,
+				Low:    bar.Low,
+				Close:  bar.Close,
+				FClose: bar.AdjClose,
+				Volume: bar.Volume,
+			})
+		}
+		response[strings.ToUpper(ticker)] = map[string][]point{"chart": chart}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) coingeckoOhlc(w http.ResponseWriter, r *http.Request, id string) {
+	bars, ok, err := s.data.Bars(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "coin not found"})
+		return
+	}
+	if value := r.URL.Query().Get("days"); value != "max" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid 'days' parameter"})
+			return
+		}
+		bars = since(bars, days)
+	}
+	response := make([][]float64, 0, len(bars))
+	for _, bar := range bars {
+		response = append(response, []float64{
+			float64(bar.Timestamp.UnixMilli()),
+			bar.Open,
+			bar.High,
+			bar.Low,
+			bar.Close,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) stooqHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	// Market suffixes are ignored, e.g. 'aapl.us' is 'AAPL'
+	ticker := strings.ToUpper(query.Get("s"))
+	if i := strings.LastIndex(ticker, "."); i > 0 {
+		ticker = ticker[:i]
+	}
+	bars, ok, err := s.stockBars(ticker)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	from, err := time.Parse("20060102", query.Get("d1"))
+	if err != nil {
+		from = time.Time{}
+	}
+	to, err := time.Parse("20060102", query.Get("d2"))
+	if err != nil {
+		to = time.Now().UTC()
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	if !ok {
+		fmt.Fprint(w, "No data")
+		return
+	}
+	fmt.Fprintln(w, "Date,Open,High,Low,Close,Volume")
+	for _, bar := range bars {
+		if bar.Timestamp.Before(from) || bar.Timestamp.After(to) {
+			continue
+		}
+		fmt.Fprintf(w, "%s,%g,%g,%g,%g,%d\n", bar.Timestamp.Format("2006-01-02"), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+	}
+}
+
+// alphaVantageQuery serves the daily time series of stocks and currency
+// pairs. Other functions are invalid calls.
+func (s *Server) alphaVantageQuery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var bars []types.Ohlc
+	var ok bool
+	var err error
+	var key string
+	switch query.Get("function") {
+	case "TIME_SERIES_DAILY_ADJUSTED":
+		key = "Time Series (Daily)"
+		bars, ok, err = s.stockBars(query.Get("symbol"))
+	case "FX_DAILY":
+		key = "Time Series FX (Daily)"
+		bars, ok, err = s.data.Bars(query.Get("from_symbol") + query.Get("to_symbol") + "=X")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"Error Message": "Invalid API call. Please retry or visit the documentation for the function.",
+		})
+		return
+	}
+	// Compact responses have the last 100 bars
+	if query.Get("outputsize") != "full" && len(bars) > 100 {
+		bars = bars[len(bars)-100:]
+	}
+	series := make(map[string]map[string]string, len(bars))
+	for _, bar := range bars {
+		series[bar.Timestamp.Format("2006-01-02")] = map[string]string{
+			"1. open":              strconv.FormatFloat(bar.Open, 'f', -1, 64),
+			"2. high":              strconv.FormatFloat(bar.High, 'f', -1, 64),
+			"3. low":               strconv.FormatFloat(bar.Low, 'f', -1, 64),
+			"4. close":             strconv.FormatFloat(bar.Close, 'f', -1, 64),
+			"5. adjusted close":    strconv.FormatFloat(bar.AdjClose, 'f', -1, 64),
+			"6. volume":            strconv.FormatInt(bar.Volume, 10),
+			"7. dividend amount":   "0.0000",
+			"8. split coefficient": "1.0",
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"Meta Data": map[string]string{
+			"1. Information": "Fake daily time series",
+			"5. Time Zone":   "US/Eastern",
+		},
+		key: series,
+	})
+}
+
+// formatInt formats an integer with thousands separators
+func formatInt(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	log.Printf("Error reading data: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,150 @@
+package fakeserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/alphavantage"
+	"github.com/regel/wsb/pkg/finance/coingecko"
+	"github.com/regel/wsb/pkg/finance/iex"
+	"github.com/regel/wsb/pkg/finance/providertest"
+	"github.com/regel/wsb/pkg/finance/stooq"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/finance/yahoo"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomWalk(t *testing.T) {
+	d := NewRandomWalk()
+	bars, ok, err := d.Bars("AAPL")
+	require.NoError(t, err)
+	require.True(t, ok)
+	again, _, _ := d.Bars("aapl")
+	require.Equal(t, bars[100].Close, again[100].Close, "Bars must be consistent")
+	other, _, _ := d.Bars("GME")
+	require.NotEqual(t, bars[100].Close, other[100].Close, "Bars must depend on the ticker")
+	for _, bar := range bars {
+		require.LessOrEqual(t, bar.Low, bar.Open)
+		require.LessOrEqual(t, bar.Low, bar.Close)
+		require.GreaterOrEqual(t, bar.High, bar.Open)
+		require.GreaterOrEqual(t, bar.High, bar.Close)
+	}
+}
+
+func TestServerConformance(t *testing.T) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	// Bars start after from so that the ranges before the first bar
+	// and after the last bar are both empty
+	data := NewRandomWalk()
+	y, m, d := from.UTC().Date()
+	data.Start = time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	server := NewServer(data)
+	fixtures := map[string]providertest.Fixture{
+		types.ProviderYahoo: {
+			NewProvider: func(url string) types.Provider {
+				return yahoo.NewProvider(url, url)
+			},
+			Ticker:   "AAPL",
+			Location: exchange,
+			Empty:    `{"chart":{"result":[],"error":null}}`,
+		},
+		types.ProviderIEX: {
+			NewProvider: func(url string) types.Provider {
+				return iex.NewProvider(url, "SECRET_TOKEN")
+			},
+			Ticker:   "AAPL",
+			Location: time.UTC,
+			Empty:    `{}`,
+		},
+		types.ProviderCoingecko: {
+			NewProvider: func(url string) types.Provider {
+				return coingecko.NewProvider(url, "")
+			},
+			Ticker: "bitcoin",
+			Empty:  `[]`,
+		},
+		types.ProviderAlphaVantage: {
+			NewProvider: func(url string) types.Provider {
+				return alphavantage.NewProvider(url, "SECRET_TOKEN")
+			},
+			Ticker:   "AAPL",
+			Location: time.UTC,
+			Empty:    `{"Meta Data":{},"Time Series (Daily)":{}}`,
+		},
+		types.ProviderStooq: {
+			NewProvider: func(url string) types.Provider {
+				return stooq.NewProvider(url)
+			},
+			Ticker:   "AAPL",
+			Location: time.UTC,
+			Empty:    "No data",
+		},
+	}
+	for name, fixture := range fixtures {
+		fixture.Handler = server
+		fixture.Interval = "1d"
+		fixture.From = from
+		fixture.To = to
+		t.Run(name, func(t *testing.T) {
+			providertest.Run(t, fixture)
+		})
+	}
+}
+
+func TestServerFileData(t *testing.T) {
+	dir := t.TempDir()
+	w, err := output.NewDirWriter(dir, output.FormatCSV, output.DirReplace)
+	require.NoError(t, err)
+	tm := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, w.Write(&types.Chart{
+		Ticker: "AAPL",
+		Ohlc: []types.Ohlc{{
+			Ticker:    "AAPL",
+			Timestamp: tm,
+			Open:      121.75,
+			High:      123.6,
+			Low:       118.62,
+			Close:     120.13,
+			AdjClose:  120.13,
+			Volume:    178154975,
+		}},
+	}))
+	data, err := NewFileData(dir, output.FormatCSV)
+	require.NoError(t, err)
+	ts := httptest.NewServer(NewServer(data))
+	defer ts.Close()
+
+	p := yahoo.NewProvider(ts.URL, ts.URL)
+	chart, err := p.GetChart(context.Background(), http.DefaultClient, "AAPL", "1d", tm, tm.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(chart.Ohlc), "Should contain one item")
+	require.InDelta(t, 120.13, chart.Ohlc[0].Close, 0.01, "Close must be the same")
+
+	_, err = p.GetChart(context.Background(), http.DefaultClient, "GME", "1d", tm, tm.AddDate(0, 0, 1))
+	require.Error(t, err, "Unknown tickers must fail")
+
+	_, err = os.Stat(filepath.Join(dir, "AAPL.csv"))
+	require.NoError(t, err)
+}
+
+func TestServerHolders(t *testing.T) {
+	ts := httptest.NewServer(NewServer(NewRandomWalk()))
+	defer ts.Close()
+
+	p := yahoo.NewProvider(ts.URL, ts.URL)
+	breakdown, institutions, funds, err := p.GetHolders(context.Background(), http.DefaultClient, "AAPL")
+	require.NoError(t, err)
+	require.Equal(t, "AAPL", breakdown.Ticker, "Ticker must be the same")
+	require.Greater(t, breakdown.NumberofInstitutionsHoldingShares, int64(0))
+	require.Equal(t, 10, len(institutions.Rows), "Should contain ten holders")
+	require.Equal(t, 10, len(funds.Rows), "Should contain ten holders")
+	require.Greater(t, institutions.Rows[0].Shares, int64(0))
+	require.False(t, institutions.Rows[0].DateReported.IsZero())
+}
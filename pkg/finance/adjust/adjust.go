@@ -0,0 +1,138 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package adjust back-adjusts OHLC series for the splits and dividends
+// carried by a types.Chart. Prices of the last bar of a series are never
+// modified: older bars are scaled so that the series is continuous.
+// Corporate actions outside of the chart time range are not accounted for.
+package adjust
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// ValidMode returns true if mode is a supported adjustment
+func ValidMode(mode string) bool {
+	switch mode {
+	case types.AdjustmentNone, types.AdjustmentSplits, types.AdjustmentAll:
+		return true
+	}
+	return false
+}
+
+// Adjust returns a copy of chart with prices and volumes adjusted
+// according to mode:
+//   - none: raw prices and volumes
+//   - splits: prices and volumes adjusted for splits
+//   - all: prices adjusted for splits and dividends, volumes adjusted for splits
+//
+// AdjClose is the close adjusted by the provider for all the corporate
+// actions and QuoteVolume is a traded value that splits do not change:
+// both are left as is.
+func Adjust(chart *types.Chart, mode string) (*types.Chart, error) {
+	if !ValidMode(mode) {
+		return nil, fmt.Errorf("Unknown adjustment '%s'", mode)
+	}
+	source := chart.Adjustment
+	if source == "" {
+		source = types.AdjustmentNone
+	}
+	if source == types.AdjustmentAll && mode != types.AdjustmentAll {
+		return nil, fmt.Errorf("Cannot remove dividend adjustments from '%s' data", chart.Ticker)
+	}
+
+	out := *chart
+	out.Adjustment = mode
+	out.Ohlc = make([]types.Ohlc, len(chart.Ohlc))
+	copy(out.Ohlc, chart.Ohlc)
+	sort.SliceStable(out.Ohlc, func(i, j int) bool {
+		return out.Ohlc[i].Timestamp.Before(out.Ohlc[j].Timestamp)
+	})
+	if source == mode {
+		return &out, nil
+	}
+
+	var dividends []float64
+	if mode == types.AdjustmentAll {
+		dividends = dividendFactors(out.Ohlc, chart.Dividends)
+	}
+	for j := range out.Ohlc {
+		row := &out.Ohlc[j]
+		ratio := splitRatio(chart.Splits, row.Timestamp)
+		price := 1.0
+		volume := 1.0
+		if source == types.AdjustmentNone {
+			// apply splits
+			price /= ratio
+			volume *= ratio
+		} else if mode == types.AdjustmentNone {
+			// remove splits applied by the provider
+			price *= ratio
+			volume /= ratio
+		}
+		if dividends != nil {
+			price *= dividends[j]
+		}
+		row.Open *= price
+		row.High *= price
+		row.Low *= price
+		row.Close *= price
+		row.Volume = int64(math.Round(float64(row.Volume) * volume))
+		row.BaseVolume *= volume
+	}
+	return &out, nil
+}
+
+// splitRatio returns the cumulated ratio of the splits effective after t
+func splitRatio(splits []types.Split, t time.Time) float64 {
+	ratio := 1.0
+	for _, split := range splits {
+		if t.Before(split.Timestamp) {
+			ratio *= split.Ratio()
+		}
+	}
+	return ratio
+}
+
+// dividendFactors returns the multiplier of each row price. Each dividend
+// scales the prices before the ex-dividend date by (1 - amount / close)
+// where close is the last close price before the ex-dividend date.
+func dividendFactors(rows []types.Ohlc, dividends []types.Dividend) []float64 {
+	factors := make([]float64, len(rows))
+	for j := range factors {
+		factors[j] = 1.0
+	}
+	for _, dividend := range dividends {
+		// index of the first row on or after the ex-dividend date
+		k := sort.Search(len(rows), func(i int) bool {
+			return !rows[i].Timestamp.Before(dividend.Timestamp)
+		})
+		if k == 0 || rows[k-1].Close <= 0 {
+			continue
+		}
+		factor := 1 - dividend.Amount/rows[k-1].Close
+		if factor <= 0 {
+			continue
+		}
+		for j := 0; j < k; j++ {
+			factors[j] *= factor
+		}
+	}
+	return factors
+}
@@ -0,0 +1,100 @@
+package adjust
+
+import (
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func day(s string) time.Time {
+	tm, _ := time.Parse("2006-01-02", s)
+	return tm
+}
+
+// Raw prices around a 2-for-1 split on 2021-01-04
+// and a dividend of 1.0 on 2021-01-05
+func sampleRawChart() *types.Chart {
+	return &types.Chart{
+		Ticker:     "XYZ",
+		Adjustment: types.AdjustmentNone,
+		Ohlc: []types.Ohlc{
+			{Ticker: "XYZ", Timestamp: day("2021-01-01"), Open: 200, High: 220, Low: 180, Close: 200, AdjClose: 99, Volume: 100, BaseVolume: 100.5},
+			{Ticker: "XYZ", Timestamp: day("2021-01-04"), Open: 100, High: 110, Low: 90, Close: 100, Volume: 200},
+			{Ticker: "XYZ", Timestamp: day("2021-01-05"), Open: 99, High: 100, Low: 98, Close: 99, Volume: 200},
+		},
+		Splits: []types.Split{
+			{Timestamp: day("2021-01-04"), Numerator: 2, Denominator: 1},
+		},
+		Dividends: []types.Dividend{
+			{Timestamp: day("2021-01-05"), Amount: 1.0},
+		},
+	}
+}
+
+func TestUnknownMode(t *testing.T) {
+	_, err := Adjust(sampleRawChart(), "dividends")
+	require.Error(t, err)
+}
+
+func TestAdjustNone(t *testing.T) {
+	out, err := Adjust(sampleRawChart(), types.AdjustmentNone)
+	require.NoError(t, err)
+	require.Equal(t, sampleRawChart().Ohlc, out.Ohlc)
+}
+
+func TestAdjustSplits(t *testing.T) {
+	in := sampleRawChart()
+	out, err := Adjust(in, types.AdjustmentSplits)
+	require.NoError(t, err)
+
+	require.Equal(t, types.AdjustmentSplits, out.Adjustment)
+	require.InDelta(t, 100.0, out.Ohlc[0].Close, 0.0001, "Close must be split adjusted")
+	require.InDelta(t, 110.0, out.Ohlc[0].High, 0.0001, "High must be split adjusted")
+	require.Equal(t, int64(200), out.Ohlc[0].Volume, "Volume must be split adjusted")
+	require.InDelta(t, 201.0, out.Ohlc[0].BaseVolume, 0.0001, "BaseVolume must be split adjusted")
+	require.InDelta(t, 99.0, out.Ohlc[0].AdjClose, 0.0001, "AdjClose must not change")
+	require.InDelta(t, 100.0, out.Ohlc[1].Close, 0.0001, "Close must not change")
+	require.Equal(t, int64(200), out.Ohlc[1].Volume, "Volume must not change")
+
+	// Input chart must not be modified
+	require.Equal(t, sampleRawChart(), in)
+}
+
+func TestAdjustAll(t *testing.T) {
+	out, err := Adjust(sampleRawChart(), types.AdjustmentAll)
+	require.NoError(t, err)
+
+	factor := 1 - 1.0/100.0
+	require.InDelta(t, 100.0*factor, out.Ohlc[0].Close, 0.0001, "Close must be fully adjusted")
+	require.InDelta(t, 90.0*factor, out.Ohlc[1].Low, 0.0001, "Low must be fully adjusted")
+	require.Equal(t, int64(200), out.Ohlc[0].Volume, "Volume must be split adjusted")
+	require.InDelta(t, 99.0, out.Ohlc[2].Close, 0.0001, "Close must not change")
+}
+
+func TestAdjustSplitAdjustedSource(t *testing.T) {
+	in, err := Adjust(sampleRawChart(), types.AdjustmentSplits)
+	require.NoError(t, err)
+
+	out, err := Adjust(in, types.AdjustmentNone)
+	require.NoError(t, err)
+	require.Equal(t, types.AdjustmentNone, out.Adjustment)
+	require.Equal(t, sampleRawChart().Ohlc, out.Ohlc)
+
+	all, err := Adjust(in, types.AdjustmentAll)
+	require.NoError(t, err)
+	expected, err := Adjust(sampleRawChart(), types.AdjustmentAll)
+	require.NoError(t, err)
+	for j := range expected.Ohlc {
+		require.InDelta(t, expected.Ohlc[j].Close, all.Ohlc[j].Close, 0.0001, "Close must be the same")
+		require.Equal(t, expected.Ohlc[j].Volume, all.Ohlc[j].Volume, "Volume must be the same")
+	}
+}
+
+func TestCannotRemoveDividends(t *testing.T) {
+	in, err := Adjust(sampleRawChart(), types.AdjustmentAll)
+	require.NoError(t, err)
+	_, err = Adjust(in, types.AdjustmentSplits)
+	require.Error(t, err)
+}
@@ -0,0 +1,291 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alphavantage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	functionDaily    = "TIME_SERIES_DAILY_ADJUSTED"
+	functionIntraday = "TIME_SERIES_INTRADAY"
+	functionFxDaily  = "FX_DAILY"
+	// Number of bars of compact responses
+	compactLen = 100
+)
+
+// Alpha Vantage intraday intervals of the chart intervals
+var intradayIntervals = map[string]string{
+	"1m":  "1min",
+	"5m":  "5min",
+	"15m": "15min",
+	"30m": "30min",
+	"60m": "60min",
+	"1h":  "60min",
+}
+
+// ThrottleError is returned when Alpha Vantage responds with a 'Note' or
+// an 'Information' message instead of data, e.g. when the number of calls
+// per minute or per day is exceeded. Responses are sent with a '200 OK'
+// status.
+type ThrottleError struct {
+	Message string
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("Alpha Vantage rate limit: %s", e.Message)
+}
+
+// Unwrap reports throttling as a '429 Too Many Requests' status
+func (e *ThrottleError) Unwrap() error {
+	return &common.StatusError{StatusCode: http.StatusTooManyRequests}
+}
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+// currencyPair returns the currencies of FX tickers, e.g. 'EUR/USD' or
+// the Yahoo Finance symbol 'EURUSD=X'
+func currencyPair(ticker string) (string, string, bool) {
+	if parts := strings.Split(ticker, "/"); len(parts) == 2 && len(parts[0]) == 3 && len(parts[1]) == 3 {
+		return strings.ToUpper(parts[0]), strings.ToUpper(parts[1]), true
+	}
+	if pair := strings.TrimSuffix(strings.ToUpper(ticker), "=X"); len(pair) == 6 && pair != strings.ToUpper(ticker) {
+		return pair[:3], pair[3:], true
+	}
+	return "", "", false
+}
+
+func getUrl(baseUrl string, token string, ticker string, interval string, from time.Time) (string, error) {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Alpha Vantage base url")
+	}
+	outputSize := "full"
+	values := url.Values{
+		"apikey": []string{token},
+	}
+	fromSymbol, toSymbol, fx := currencyPair(ticker)
+	switch {
+	case fx && interval == "1d":
+		values.Set("function", functionFxDaily)
+		values.Set("from_symbol", fromSymbol)
+		values.Set("to_symbol", toSymbol)
+	case fx:
+		return "", fmt.Errorf("Unsupported interval '%s' of currency pair '%s'", interval, ticker)
+	case interval == "1d":
+		values.Set("function", functionDaily)
+		values.Set("symbol", ticker)
+		// Compact responses have the last 100 daily bars
+		if time.Since(from) < compactLen*24*time.Hour {
+			outputSize = "compact"
+		}
+	default:
+		i, ok := intradayIntervals[interval]
+		if !ok {
+			return "", fmt.Errorf("Unsupported interval '%s'", interval)
+		}
+		values.Set("function", functionIntraday)
+		values.Set("symbol", ticker)
+		values.Set("interval", i)
+	}
+	values.Set("outputsize", outputSize)
+	relative := &url.URL{
+		Path:     "/query",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String(), nil
+}
+
+// Bar of a time series. Keys are numbered, e.g. '1. open'. Daily adjusted
+// bars also have the adjusted close, the dividend amount and the split
+// coefficient of the day.
+type Bar map[string]string
+
+func (b Bar) value(name string) (float64, error) {
+	for key, v := range b {
+		if strings.HasSuffix(key, ". "+name) {
+			return strconv.ParseFloat(v, 64)
+		}
+	}
+	return 0, fmt.Errorf("Missing '%s' value", name)
+}
+
+// decodeResponse returns the time zone and the bars of the time series
+// of the response
+func decodeResponse(response map[string]json.RawMessage) (*time.Location, map[string]Bar, error) {
+	for _, key := range []string{"Note", "Information"} {
+		if raw, ok := response[key]; ok {
+			var message string
+			json.Unmarshal(raw, &message)
+			return nil, nil, &ThrottleError{Message: message}
+		}
+	}
+	if raw, ok := response["Error Message"]; ok {
+		var message string
+		json.Unmarshal(raw, &message)
+		return nil, nil, errors.New(message)
+	}
+	loc := time.UTC
+	var meta map[string]string
+	if err := json.Unmarshal(response["Meta Data"], &meta); err == nil {
+		for key, v := range meta {
+			if strings.HasSuffix(key, "Time Zone") {
+				if l, err := time.LoadLocation(v); err == nil {
+					loc = l
+				}
+			}
+		}
+	}
+	for key, raw := range response {
+		if !strings.HasPrefix(key, "Time Series") {
+			continue
+		}
+		series := make(map[string]Bar)
+		if err := json.Unmarshal(raw, &series); err != nil {
+			return nil, nil, err
+		}
+		return loc, series, nil
+	}
+	return nil, nil, errors.New("Missing time series")
+}
+
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	queryUrl, err := getUrl(p.AlphaVantageQueryUrl, p.AlphaVantageSecretToken, ticker, interval, from)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := map[string]json.RawMessage{}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	loc, series, err := decodeResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	// Daily bars are dated. Intraday bars are in the time zone of the exchange.
+	_, intraday := intradayIntervals[interval]
+	layout := "2006-01-02"
+	if intraday {
+		layout = "2006-01-02 15:04:05"
+	} else {
+		loc = time.UTC
+	}
+	chart := &types.Chart{
+		Ohlc:      make([]types.Ohlc, 0),
+		Ticker:    ticker,
+		Dividends: make([]types.Dividend, 0),
+		Splits:    make([]types.Split, 0),
+	}
+	for date, bar := range series {
+		t, err := time.ParseInLocation(layout, date, loc)
+		if err != nil {
+			return nil, err
+		}
+		if !timeWithinRange(t, from, to) {
+			continue
+		}
+		values := make([]float64, 4)
+		for j, name := range []string{"open", "high", "low", "close"} {
+			if values[j], err = bar.value(name); err != nil {
+				return nil, err
+			}
+		}
+		ohlc := types.Ohlc{
+			Ticker:    ticker,
+			Timestamp: t,
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			AdjClose:  values[3],
+		}
+		// Currency pairs have no volume
+		if volume, err := bar.value("volume"); err == nil {
+			ohlc.Volume = int64(volume)
+		}
+		if adjClose, err := bar.value("adjusted close"); err == nil {
+			ohlc.AdjClose = adjClose
+		}
+		if amount, err := bar.value("dividend amount"); err == nil && amount > 0 {
+			chart.Dividends = append(chart.Dividends, types.Dividend{
+				Timestamp: t,
+				Amount:    amount,
+			})
+		}
+		if ratio, err := bar.value("split coefficient"); err == nil && ratio != 1 && ratio > 0 {
+			chart.Splits = append(chart.Splits, types.Split{
+				Timestamp:   t,
+				Numerator:   ratio,
+				Denominator: 1,
+			})
+		}
+		chart.Ohlc = append(chart.Ohlc, ohlc)
+	}
+	sort.Slice(chart.Ohlc, func(i, j int) bool {
+		return chart.Ohlc[i].Timestamp.Before(chart.Ohlc[j].Timestamp)
+	})
+	sort.Slice(chart.Dividends, func(i, j int) bool {
+		return chart.Dividends[i].Timestamp.Before(chart.Dividends[j].Timestamp)
+	})
+	sort.Slice(chart.Splits, func(i, j int) bool {
+		return chart.Splits[i].Timestamp.Before(chart.Splits[j].Timestamp)
+	})
+	// Daily prices are not adjusted. Intraday prices are adjusted for
+	// splits and dividends.
+	chart.Adjustment = types.AdjustmentNone
+	if intraday {
+		chart.Adjustment = types.AdjustmentAll
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return false
+}
+
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	// not implemented
+}
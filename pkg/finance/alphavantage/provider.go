@@ -0,0 +1,18 @@
+package alphavantage
+
+import (
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	AlphaVantageQueryUrl    string
+	AlphaVantageSecretToken string
+}
+
+func NewProvider(AlphaVantageQueryUrl string, AlphaVantageSecretToken string) types.Provider {
+	return &Provider{
+		AlphaVantageQueryUrl:    AlphaVantageQueryUrl,
+		AlphaVantageSecretToken: AlphaVantageSecretToken,
+	}
+}
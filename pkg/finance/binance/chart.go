@@ -0,0 +1,240 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of klines of a response
+	pageLimit = 1000
+	// Maximum number of pages of a chart
+	maxPages = 1000
+)
+
+// Binance kline intervals of the chart intervals. Native Binance
+// intervals are accepted as well, e.g. '4h' or '1M'.
+var intervals = map[string]string{
+	"1m":  "1m",
+	"3m":  "3m",
+	"5m":  "5m",
+	"15m": "15m",
+	"30m": "30m",
+	"60m": "1h",
+	"1h":  "1h",
+	"2h":  "2h",
+	"4h":  "4h",
+	"6h":  "6h",
+	"8h":  "8h",
+	"12h": "12h",
+	"1d":  "1d",
+	"3d":  "3d",
+	"1wk": "1w",
+	"1w":  "1w",
+	"1mo": "1M",
+	"1M":  "1M",
+}
+
+// APIError is the error message of responses with a non-OK status, e.g.
+// '{"code":-1121,"msg":"Invalid symbol."}'
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"code"`
+	Message    string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Binance error %d: %s", e.Code, e.Message)
+}
+
+// Unwrap reports the HTTP status of the response
+func (e *APIError) Unwrap() error {
+	return &common.StatusError{StatusCode: e.StatusCode}
+}
+
+// Kline is the bar of the window starting at OpenTime, in milliseconds.
+// Prices and volumes are strings in responses.
+type Kline struct {
+	OpenTime    int64
+	Open        float64
+	High        float64
+	Low         float64
+	Close       float64
+	Volume      float64
+	CloseTime   int64
+	QuoteVolume float64
+	Trades      int64
+}
+
+// UnmarshalJSON decodes a kline from its array representation
+func (k *Kline) UnmarshalJSON(b []byte) error {
+	var fields []interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 9 {
+		return fmt.Errorf("Invalid kline %s", b)
+	}
+	openTime, ok1 := fields[0].(float64)
+	closeTime, ok2 := fields[6].(float64)
+	trades, ok3 := fields[8].(float64)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("Invalid kline %s", b)
+	}
+	k.OpenTime = int64(openTime)
+	k.CloseTime = int64(closeTime)
+	k.Trades = int64(trades)
+	values := map[int]*float64{1: &k.Open, 2: &k.High, 3: &k.Low, 4: &k.Close, 5: &k.Volume, 7: &k.QuoteVolume}
+	for i, v := range values {
+		s, ok := fields[i].(string)
+		if !ok {
+			return fmt.Errorf("Invalid kline %s", b)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*v = f
+	}
+	return nil
+}
+
+// Symbol returns the Binance symbol of a trading pair, e.g. 'BTCUSDT' for
+// 'btcusdt', 'BTC-USDT' or 'BTC/USDT'
+func Symbol(ticker string) string {
+	r := strings.NewReplacer("-", "", "/", "", "_", "")
+	return strings.ToUpper(r.Replace(ticker))
+}
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+func (p Provider) getUrl(ticker string, interval string, start int64, end int64) string {
+	base, err := url.Parse(p.BinanceQueryUrl)
+	if err != nil {
+		panic("Can't parse Binance base url")
+	}
+	values := url.Values{
+		"symbol":    []string{Symbol(ticker)},
+		"interval":  []string{interval},
+		"startTime": []string{strconv.FormatInt(start, 10)},
+		"endTime":   []string{strconv.FormatInt(end, 10)},
+		"limit":     []string{strconv.Itoa(pageLimit)},
+	}
+	relative := &url.URL{
+		Path:     "/api/v3/klines",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+func (p Provider) get(c context.Context, client *http.Client, queryUrl string) ([]Kline, error) {
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: res.StatusCode}
+		if json.NewDecoder(res.Body).Decode(apiErr) != nil || apiErr.Message == "" {
+			return nil, &common.StatusError{StatusCode: res.StatusCode}
+		}
+		return nil, apiErr
+	}
+
+	var response []Kline
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetChart requests the klines of the time range by pages of at most
+// 1000 klines, starting each page after the last kline of the previous one
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	binanceInterval, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported interval '%s'", interval)
+	}
+	points := make([]types.Ohlc, 0)
+	start, end := from.UnixMilli(), to.UnixMilli()
+	for page := 0; start <= end; page++ {
+		if page == maxPages {
+			return nil, fmt.Errorf("Too many pages of '%s' klines", ticker)
+		}
+		klines, err := p.get(c, client, p.getUrl(ticker, binanceInterval, start, end))
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range klines {
+			t := time.UnixMilli(k.OpenTime).UTC()
+			if timeWithinRange(t, from, to) {
+				points = append(points, types.Ohlc{
+					Ticker:    ticker,
+					Timestamp: t,
+					Open:      k.Open,
+					High:      k.High,
+					Low:       k.Low,
+					Close:     k.Close,
+					AdjClose:  k.Close,
+					// Volume of the base asset, e.g. BTC for BTCUSDT
+					Volume:      int64(math.Round(k.Volume)),
+					BaseVolume:  k.Volume,
+					QuoteVolume: k.QuoteVolume,
+					Trades:      k.Trades,
+				})
+			}
+		}
+		if len(klines) < pageLimit {
+			break
+		}
+		start = klines[len(klines)-1].OpenTime + 1
+	}
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentNone,
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return false
+}
+
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	// not implemented
+}
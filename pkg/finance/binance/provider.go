@@ -0,0 +1,16 @@
+package binance
+
+import (
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	BinanceQueryUrl string
+}
+
+func NewProvider(BinanceQueryUrl string) types.Provider {
+	return &Provider{
+		BinanceQueryUrl: BinanceQueryUrl,
+	}
+}
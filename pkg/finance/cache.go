@@ -0,0 +1,268 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package finance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+const (
+	// Time to live of charts whose bars are all closed
+	DefaultCacheTTL = 7 * 24 * time.Hour
+	// Time to live of charts that may include the current, still open, bar
+	DefaultCacheCurrentTTL = time.Minute
+)
+
+// Duration of the bars of each chart interval. Unknown intervals
+// are assumed to be daily bars.
+var intervalDurations = map[string]time.Duration{
+	"1m":  time.Minute,
+	"2m":  2 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"60m": time.Hour,
+	"90m": 90 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+	"5d":  5 * 24 * time.Hour,
+	"1wk": 7 * 24 * time.Hour,
+	"1mo": 31 * 24 * time.Hour,
+	"3mo": 92 * 24 * time.Hour,
+}
+
+// Cache stores the charts returned by providers on disk, one file per
+// provider, ticker, interval and time range: '<dir>/<provider>/<key>.json'
+type Cache struct {
+	dir        string
+	ttl        time.Duration
+	currentTTL time.Duration
+	now        func() time.Time
+}
+
+// CacheStats summarizes the cache entries of one provider
+type CacheStats struct {
+	Provider string
+	Entries  int
+	Expired  int
+	Bytes    int64
+}
+
+// cacheEntry is a cached chart. Entries expire after the TTL configured
+// when they are read so that a shorter TTL applies to existing entries.
+type cacheEntry struct {
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+	// The time range may include a bar that was not closed yet
+	Current bool         `json:"current"`
+	Chart   *types.Chart `json:"chart"`
+}
+
+// NewCache creates a cache stored in dir. Charts are kept for ttl, or for
+// currentTTL if the time range may include a bar that is not closed yet.
+// Zero durations default to DefaultCacheTTL and DefaultCacheCurrentTTL.
+func NewCache(dir string, ttl time.Duration, currentTTL time.Duration) *Cache {
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	if currentTTL == 0 {
+		currentTTL = DefaultCacheCurrentTTL
+	}
+	return &Cache{
+		dir:        dir,
+		ttl:        ttl,
+		currentTTL: currentTTL,
+		now:        time.Now,
+	}
+}
+
+// cacheKey identifies a chart. The options are the provider settings
+// that change its charts, e.g. adjusted prices.
+func cacheKey(provider string, options string, ticker string, interval string, from time.Time, to time.Time) string {
+	key := fmt.Sprintf("%s|%s|%s|%d|%d", provider, ticker, interval, from.Unix(), to.Unix())
+	if options != "" {
+		key += "|" + options
+	}
+	return key
+}
+
+func (c *Cache) path(provider string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, provider, hex.EncodeToString(sum[:])+".json")
+}
+
+// isEntry returns true for the names of the entry files written by Put
+func isEntry(name string) bool {
+	sum, ok := strings.CutSuffix(name, ".json")
+	if !ok || len(sum) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
+
+// isCurrent returns true if a chart ending at 'to' may include a bar
+// that is not closed yet
+func (c *Cache) isCurrent(interval string, to time.Time) bool {
+	d, ok := intervalDurations[interval]
+	if !ok {
+		d = intervalDurations["1d"]
+	}
+	return to.After(c.now().Add(-d))
+}
+
+// expired returns true if the entry is older than its TTL. Entries of
+// previous versions have no creation time and are always expired.
+func (c *Cache) expired(entry cacheEntry) bool {
+	ttl := c.ttl
+	if entry.Current {
+		ttl = c.currentTTL
+	}
+	return !c.now().Before(entry.Created.Add(ttl))
+}
+
+// Get returns the cached chart if it exists and has not expired
+func (c *Cache) Get(provider string, options string, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, bool) {
+	key := cacheKey(provider, options, ticker, interval, from, to)
+	b, err := os.ReadFile(c.path(provider, key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(b, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Key != key || entry.Chart == nil || c.expired(entry) {
+		return nil, false
+	}
+	return entry.Chart, true
+}
+
+// Put stores the chart in the cache
+func (c *Cache) Put(provider string, options string, ticker string, interval string, from time.Time, to time.Time, chart *types.Chart) error {
+	key := cacheKey(provider, options, ticker, interval, from, to)
+	path := c.path(provider, key)
+	b, err := json.Marshal(cacheEntry{
+		Key:     key,
+		Created: c.now(),
+		Current: c.isCurrent(interval, to),
+		Chart:   chart,
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Clear removes all the cache entries. Only the files and provider
+// directories created by the cache are removed, the cache directory
+// may be shared with other files.
+func (c *Cache) Clear() error {
+	for _, provider := range types.Providers {
+		dir := filepath.Join(c.dir, provider)
+		files, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		kept := 0
+		for _, f := range files {
+			// Temporary files are left behind by interrupted writes
+			tmp, _ := filepath.Match(".*.tmp", f.Name())
+			if f.IsDir() || (!isEntry(f.Name()) && !tmp) {
+				kept++
+				continue
+			}
+			if err = os.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+		// Directories holding other files are kept
+		if kept == 0 {
+			if err = os.Remove(dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stats returns the number and size of the cache entries of each provider
+func (c *Cache) Stats() ([]CacheStats, error) {
+	stats := make([]CacheStats, 0)
+	providers, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		if !p.IsDir() {
+			continue
+		}
+		s := CacheStats{Provider: p.Name()}
+		files, err := os.ReadDir(filepath.Join(c.dir, p.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || !isEntry(f.Name()) {
+				continue
+			}
+			path := filepath.Join(c.dir, p.Name(), f.Name())
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			s.Entries++
+			s.Bytes += int64(len(b))
+			var entry cacheEntry
+			if err = json.Unmarshal(b, &entry); err != nil || c.expired(entry) {
+				s.Expired++
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
@@ -0,0 +1,91 @@
+package finance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheExpiration(t *testing.T) {
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCache(t.TempDir(), 24*time.Hour, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	from := now.AddDate(0, -1, 0)
+	closed := now.AddDate(0, 0, -2)
+	current := now.AddDate(0, 0, -1).Add(time.Hour)
+	chart := &types.Chart{Ticker: "AAPL", Ohlc: []types.Ohlc{}}
+	require.NoError(t, cache.Put("yahoo", "", "AAPL", "1d", from, closed, chart))
+	require.NoError(t, cache.Put("yahoo", "", "AAPL", "1d", from, current, chart))
+
+	_, ok := cache.Get("yahoo", "", "AAPL", "1d", from, closed)
+	require.True(t, ok, "Closed bars must be cached")
+	_, ok = cache.Get("iex", "", "AAPL", "1d", from, closed)
+	require.False(t, ok, "Entries must not be shared by providers")
+	_, ok = cache.Get("yahoo", "adjusted=false", "AAPL", "1d", from, closed)
+	require.False(t, ok, "Entries must not be shared by provider options")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get("yahoo", "", "AAPL", "1d", from, closed)
+	require.True(t, ok, "Closed bars must use the long TTL")
+	_, ok = cache.Get("yahoo", "", "AAPL", "1d", from, current)
+	require.False(t, ok, "Current bar must use the short TTL")
+
+	stats, err := cache.Stats()
+	require.NoError(t, err)
+	require.Equal(t, []CacheStats{{Provider: "yahoo", Entries: 2, Expired: 1, Bytes: stats[0].Bytes}}, stats)
+
+	require.NoError(t, cache.Clear())
+	stats, err = cache.Stats()
+	require.NoError(t, err)
+	require.Empty(t, stats)
+}
+
+func TestCacheConfiguredTTL(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewCache(dir, 24*time.Hour, time.Minute)
+	cache.now = func() time.Time { return now }
+	from := now.AddDate(0, -1, 0)
+	to := now.AddDate(0, 0, -2)
+	require.NoError(t, cache.Put("yahoo", "", "AAPL", "1d", from, to, &types.Chart{Ticker: "AAPL"}))
+
+	now = now.Add(2 * time.Hour)
+	_, ok := cache.Get("yahoo", "", "AAPL", "1d", from, to)
+	require.True(t, ok, "Entry must not be expired")
+
+	shorter := NewCache(dir, time.Hour, time.Minute)
+	shorter.now = cache.now
+	_, ok = shorter.Get("yahoo", "", "AAPL", "1d", from, to)
+	require.False(t, ok, "Entry must expire after the configured TTL")
+	stats, err := shorter.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats[0].Expired, "Entry must be reported as expired")
+}
+
+func TestCacheClearKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(dir, 0, 0)
+	now := time.Now()
+	require.NoError(t, cache.Put("yahoo", "", "AAPL", "1d", now.AddDate(0, -1, 0), now, &types.Chart{Ticker: "AAPL"}))
+	require.NoError(t, cache.Put("iex", "", "AAPL", "1d", now.AddDate(0, -1, 0), now, &types.Chart{Ticker: "AAPL"}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "projects"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "iex", "notes.json"), []byte("{}"), 0644))
+
+	require.NoError(t, cache.Clear())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Equal(t, []string{"iex", "notes.txt", "projects"}, names, "Only cache files must be removed")
+	files, err := os.ReadDir(filepath.Join(dir, "iex"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files), "Other files must be kept")
+}
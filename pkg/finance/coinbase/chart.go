@@ -0,0 +1,186 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of candles of a response
+	windowLimit = 300
+	// Maximum number of requests of a chart
+	maxWindows = 1000
+)
+
+// Granularity in seconds of the chart intervals
+var intervals = map[string]int64{
+	"1m":  60,
+	"5m":  300,
+	"15m": 900,
+	"60m": 3600,
+	"1h":  3600,
+	"6h":  21600,
+	"1d":  86400,
+}
+
+// APIError is the error message of responses with a non-OK status, e.g.
+// '{"message":"NotFound"}'
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Coinbase error: %s", e.Message)
+}
+
+// Unwrap reports the HTTP status of the response
+func (e *APIError) Unwrap() error {
+	return &common.StatusError{StatusCode: e.StatusCode}
+}
+
+// Product returns the Coinbase product id of a trading pair, e.g. 'BTC-USD'
+// for 'btc-usd' or 'BTC/USD'
+func Product(ticker string) string {
+	return strings.ToUpper(strings.ReplaceAll(ticker, "/", "-"))
+}
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+func (p Provider) getUrl(ticker string, granularity int64, start time.Time, end time.Time) string {
+	base, err := url.Parse(p.CoinbaseQueryUrl)
+	if err != nil {
+		panic("Can't parse Coinbase base url")
+	}
+	values := url.Values{
+		"granularity": []string{strconv.FormatInt(granularity, 10)},
+		"start":       []string{start.UTC().Format(time.RFC3339)},
+		"end":         []string{end.UTC().Format(time.RFC3339)},
+	}
+	relative := &url.URL{
+		Path:     fmt.Sprintf("/products/%s/candles", url.PathEscape(Product(ticker))),
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// get returns the candles of a window, each candle being
+// [ time, low, high, open, close, volume ]
+func (p Provider) get(c context.Context, client *http.Client, queryUrl string) ([][]float64, error) {
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: res.StatusCode}
+		if json.NewDecoder(res.Body).Decode(apiErr) != nil || apiErr.Message == "" {
+			return nil, &common.StatusError{StatusCode: res.StatusCode}
+		}
+		return nil, apiErr
+	}
+
+	var response [][]float64
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetChart requests the candles of the time range by windows of at most
+// 300 candles. Candles of responses are sorted newest first.
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	granularity, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported interval '%s'", interval)
+	}
+	step := time.Duration(granularity) * time.Second
+	points := make([]types.Ohlc, 0)
+	start := from
+	for window := 0; !start.After(to); window++ {
+		if window == maxWindows {
+			return nil, fmt.Errorf("Too many windows of '%s' candles", ticker)
+		}
+		end := start.Add((windowLimit - 1) * step)
+		if end.After(to) {
+			end = to
+		}
+		candles, err := p.get(c, client, p.getUrl(ticker, granularity, start, end))
+		if err != nil {
+			return nil, err
+		}
+		for _, candle := range candles {
+			if len(candle) < 6 {
+				return nil, fmt.Errorf("Invalid candle %v", candle)
+			}
+			t := time.Unix(int64(candle[0]), 0).UTC()
+			if timeWithinRange(t, start, end) {
+				points = append(points, types.Ohlc{
+					Ticker:    ticker,
+					Timestamp: t,
+					Open:      candle[3],
+					High:      candle[2],
+					Low:       candle[1],
+					Close:     candle[4],
+					AdjClose:  candle[4],
+					// Volume of the base asset, e.g. BTC for BTC-USD
+					Volume:     int64(math.Round(candle[5])),
+					BaseVolume: candle[5],
+				})
+			}
+		}
+		start = end.Add(step)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentNone,
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return false
+}
+
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	// not implemented
+}
@@ -0,0 +1,16 @@
+package coinbase
+
+import (
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	CoinbaseQueryUrl string
+}
+
+func NewProvider(CoinbaseQueryUrl string) types.Provider {
+	return &Provider{
+		CoinbaseQueryUrl: CoinbaseQueryUrl,
+	}
+}
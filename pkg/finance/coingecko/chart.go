@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/regel/wsb/pkg/common"
 	"github.com/regel/wsb/pkg/finance/types"
 	"log"
 	"net/http"
@@ -56,7 +57,7 @@ func getUrl(baseUrl string, ticker string, interval string, from time.Time, to t
 
 	values := url.Values{
 		"days":        []string{time_ago},
-		"vs_currency": []string{"usd"},
+		"vs_currency": []string{vsCurrency},
 	}
 	relative := &url.URL{
 		Path:     fmt.Sprintf("/api/v3/coins/%s/ohlc", ticker),
@@ -66,22 +67,20 @@ func getUrl(baseUrl string, ticker string, interval string, from time.Time, to t
 	return base.ResolveReference(relative).String()
 }
 
-func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) ([]types.Ohlc, error) {
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
 	queryUrl := getUrl(p.CoingeckoQueryUrl, ticker, interval, from, to)
 	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ctx, cancel := context.WithTimeout(c, time.Duration(5*time.Second))
-	defer cancel()
-	req = req.WithContext(ctx)
+	req = req.WithContext(c)
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Non-OK HTTP status: %d", res.StatusCode)
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
 	}
 
 	var response [][]float64
@@ -101,11 +100,17 @@ func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string,
 				High:      point[2],
 				Low:       point[3],
 				Close:     point[4],
+				AdjClose:  point[4],
 			}
 			points = append(points, ohlc)
 		}
 	}
-	return points, err
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentNone,
+	}
+	return chart, nil
 }
 
 func (p Provider) BatchSupported() bool {
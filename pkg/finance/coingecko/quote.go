@@ -0,0 +1,101 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coingecko
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	vsCurrency = "usd"
+)
+
+type SimplePrice struct {
+	Usd           float64 `json:"usd"`
+	Usd24hChange  float64 `json:"usd_24h_change"`
+	LastUpdatedAt int64   `json:"last_updated_at"`
+}
+
+func getQuoteUrl(baseUrl string, tickers []string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Coingecko base url")
+	}
+	values := url.Values{
+		"ids":                     []string{strings.Join(tickers, ",")},
+		"vs_currencies":           []string{vsCurrency},
+		"include_24hr_change":     []string{"true"},
+		"include_last_updated_at": []string{"true"},
+	}
+	relative := &url.URL{
+		Path:     "/api/v3/simple/price",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// Cryptocurrencies trade around the clock: the change
+// is computed over the last 24 hours.
+func (p Provider) GetQuotes(c context.Context, client *http.Client, tickers []string) ([]types.Quote, error) {
+	queryUrl := getQuoteUrl(p.CoingeckoQueryUrl, tickers)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := map[string]SimplePrice{}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make([]types.Quote, 0)
+	for ticker, price := range response {
+		// Crypto markets have no sessions. The previous close is the price
+		// 24 hours ago and there is no open price.
+		q := types.Quote{
+			Ticker:        ticker,
+			Timestamp:     time.Unix(price.LastUpdatedAt, 0),
+			Currency:      strings.ToUpper(vsCurrency),
+			Price:         price.Usd,
+			ChangePercent: price.Usd24hChange,
+			MarketState:   types.MarketStateRegular,
+		}
+		// The previous close is unknown after a change of -100%
+		if ratio := 1 + price.Usd24hChange/100; ratio != 0 {
+			q.PreviousClose = price.Usd / ratio
+			q.Change = price.Usd - q.PreviousClose
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
@@ -0,0 +1,90 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coingecko
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	symbolTypeCrypto = "CRYPTOCURRENCY"
+)
+
+type SearchResponse struct {
+	Coins []SearchCoin `json:"coins"`
+}
+
+type SearchCoin struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Symbol string `json:"symbol"`
+}
+
+func getSearchUrl(baseUrl string, query string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Coingecko base url")
+	}
+	values := url.Values{
+		"query": []string{query},
+	}
+	relative := &url.URL{
+		Path:     "/api/v3/search",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// The Id of coins is the ticker name to use with other methods
+func (p Provider) SearchSymbols(c context.Context, client *http.Client, query string) ([]types.Symbol, error) {
+	queryUrl := getSearchUrl(p.CoingeckoQueryUrl, query)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := &SearchResponse{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]types.Symbol, 0)
+	for _, coin := range response.Coins {
+		symbols = append(symbols, types.Symbol{
+			Symbol: strings.ToUpper(coin.Symbol),
+			Name:   coin.Name,
+			Type:   symbolTypeCrypto,
+			Id:     coin.Id,
+		})
+	}
+	return symbols, nil
+}
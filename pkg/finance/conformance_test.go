@@ -0,0 +1,264 @@
+package finance
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/alphavantage"
+	"github.com/regel/wsb/pkg/finance/binance"
+	"github.com/regel/wsb/pkg/finance/coinbase"
+	"github.com/regel/wsb/pkg/finance/coingecko"
+	"github.com/regel/wsb/pkg/finance/iex"
+	"github.com/regel/wsb/pkg/finance/kraken"
+	"github.com/regel/wsb/pkg/finance/polygon"
+	"github.com/regel/wsb/pkg/finance/providertest"
+	"github.com/regel/wsb/pkg/finance/stooq"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/finance/yahoo"
+)
+
+const conformanceYahooChartResponse = `
+{
+	"chart": {
+		"result": [{
+			"meta": {
+				"symbol": "AAPL",
+				"timezone": "EST",
+				"exchangeTimezoneName": "America/New_York",
+				"dataGranularity": "1d"
+			},
+			"timestamp": [1614868200, 1614954600, 1615213800],
+			"indicators": {
+				"quote": [{
+					"close": [120.13, 121.41, 116.36],
+					"high": [123.6, 121.94, 121.0],
+					"open": [121.75, 120.98, 120.93],
+					"volume": [178154975, 153766601, 154376610],
+					"low": [118.62, 117.57, 116.21]
+				}],
+				"adjclose": [{
+					"adjclose": [119.27, 120.54, 115.52]
+				}]
+			}
+		}],
+		"error": null
+	}
+}
+`
+
+const conformanceIexChartResponse = `
+{
+	"AAPL": {
+		"chart": [
+			{"date": "2021-03-04", "open": 121.75, "high": 123.6, "low": 118.62, "close": 120.13, "fClose": 119.27, "volume": 178154975},
+			{"date": "2021-03-05", "open": 120.98, "high": 121.94, "low": 117.57, "close": 121.41, "fClose": 120.54, "volume": 153766601},
+			{"date": "2021-03-08", "open": 120.93, "high": 121.0, "low": 116.21, "close": 116.36, "fClose": 115.52, "volume": 154376610}
+		]
+	}
+}
+`
+
+const conformanceCoingeckoChartResponse = `
+[
+	[1614816000000, 48415.82, 49391.63, 47504.09, 48374.09],
+	[1615161600000, 48918.47, 51727.73, 48918.47, 50971.14],
+	[1615507200000, 56020.49, 57553.69, 55805.33, 57553.69]
+]
+`
+
+const conformanceStooqChartResponse = `Date,Open,High,Low,Close,Volume
+2021-03-04,121.75,123.6,118.62,120.13,178154975
+2021-03-05,120.98,121.94,117.57,121.41,153766601
+2021-03-08,120.93,121.0,116.21,116.36,154376610
+`
+
+const conformanceAlphaVantageChartResponse = `
+{
+	"Meta Data": {
+		"2. Symbol": "AAPL",
+		"5. Time Zone": "US/Eastern"
+	},
+	"Time Series (Daily)": {
+		"2021-03-08": {"1. open": "120.93", "2. high": "121.0", "3. low": "116.21", "4. close": "116.36", "5. adjusted close": "115.52", "6. volume": "154376610", "7. dividend amount": "0.0", "8. split coefficient": "1.0"},
+		"2021-03-05": {"1. open": "120.98", "2. high": "121.94", "3. low": "117.57", "4. close": "121.41", "5. adjusted close": "120.54", "6. volume": "153766601", "7. dividend amount": "0.0", "8. split coefficient": "1.0"},
+		"2021-03-04": {"1. open": "121.75", "2. high": "123.6", "3. low": "118.62", "4. close": "120.13", "5. adjusted close": "119.27", "6. volume": "178154975", "7. dividend amount": "0.0", "8. split coefficient": "1.0"}
+	}
+}
+`
+
+const conformancePolygonChartResponse = `
+{
+	"ticker": "AAPL",
+	"status": "OK",
+	"adjusted": true,
+	"resultsCount": 3,
+	"results": [
+		{"v": 178154975, "o": 121.75, "c": 120.13, "h": 123.6, "l": 118.62, "t": 1614834000000},
+		{"v": 153766601, "o": 120.98, "c": 121.41, "h": 121.94, "l": 117.57, "t": 1614920400000},
+		{"v": 154376610, "o": 120.93, "c": 116.36, "h": 121.0, "l": 116.21, "t": 1615179600000}
+	]
+}
+`
+
+const conformanceBinanceChartResponse = `
+[
+	[1614816000000, "50522.30", "51773.88", "47500.00", "48527.30", "58985.37", 1614902399999, "2915193634.07", 1912436, "28835.36", "1425139025.47", "0"],
+	[1614902400000, "48527.31", "49394.00", "46300.00", "48756.23", "67862.38", 1614988799999, "3222440101.33", 2104287, "33055.51", "1569802476.20", "0"],
+	[1615161600000, "51174.73", "52443.23", "49274.67", "52375.17", "66425.08", 1615247999999, "3387542216.42", 1957219, "33166.69", "1691553542.77", "0"]
+]
+`
+
+// Coinbase candles are sorted newest first
+const conformanceCoinbaseChartResponse = `
+[
+	[1615161600, 49274.67, 52443.23, 51174.73, 52375.17, 19453.20],
+	[1614902400, 46300.00, 49394.00, 48527.31, 48756.23, 21302.61],
+	[1614816000, 47500.00, 51773.88, 50522.30, 48527.30, 17640.55]
+]
+`
+
+const conformanceKrakenChartResponse = `
+{
+	"error": [],
+	"result": {
+		"XXBTZUSD": [
+			[1614816000, "50522.3", "51773.8", "47500.0", "48527.3", "49420.5", "4015.37", 41824],
+			[1614902400, "48527.3", "49394.0", "46300.0", "48756.2", "47902.1", "4892.44", 47215],
+			[1615161600, "51174.7", "52443.2", "49274.6", "52375.1", "51082.7", "3721.09", 38740]
+		],
+		"last": 1615161600
+	}
+}
+`
+
+func fixtureHandler(path string, rsp string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, path) {
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		fmt.Fprintln(w, rsp)
+	})
+}
+
+func TestProviderConformance(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	tm, _ := time.Parse("2006-01-02", "2021-03-01")
+	fixtures := map[string]providertest.Fixture{
+		types.ProviderYahoo: {
+			NewProvider: func(url string) types.Provider {
+				return yahoo.NewProvider(url, url)
+			},
+			Handler:  fixtureHandler("/v8/finance/chart/AAPL", conformanceYahooChartResponse),
+			Ticker:   "AAPL",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: loc,
+			Empty:    `{"chart":{"result":[],"error":null}}`,
+		},
+		types.ProviderIEX: {
+			NewProvider: func(url string) types.Provider {
+				return iex.NewProvider(url, "SECRET_TOKEN")
+			},
+			Handler:  fixtureHandler("/v1/stock/market/batch", conformanceIexChartResponse),
+			Ticker:   "AAPL",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    `{}`,
+		},
+		types.ProviderCoingecko: {
+			NewProvider: func(url string) types.Provider {
+				return coingecko.NewProvider(url, "")
+			},
+			Handler:  fixtureHandler("/api/v3/coins/bitcoin/ohlc", conformanceCoingeckoChartResponse),
+			Ticker:   "bitcoin",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Empty:    `[]`,
+		},
+		types.ProviderStooq: {
+			NewProvider: func(url string) types.Provider {
+				return stooq.NewProvider(url)
+			},
+			Handler:  fixtureHandler("/q/d/l/", conformanceStooqChartResponse),
+			Ticker:   "AAPL",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    "No data",
+		},
+		types.ProviderAlphaVantage: {
+			NewProvider: func(url string) types.Provider {
+				return alphavantage.NewProvider(url, "SECRET_TOKEN")
+			},
+			Handler:  fixtureHandler("/query", conformanceAlphaVantageChartResponse),
+			Ticker:   "AAPL",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    `{"Meta Data":{},"Time Series (Daily)":{}}`,
+		},
+		types.ProviderPolygon: {
+			NewProvider: func(url string) types.Provider {
+				return polygon.NewProvider(url, "SECRET_TOKEN", true)
+			},
+			Handler:  fixtureHandler("/v2/aggs/ticker/AAPL/range/1/day/", conformancePolygonChartResponse),
+			Ticker:   "AAPL",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: loc,
+			Empty:    `{"status":"OK","resultsCount":0,"results":[]}`,
+		},
+		types.ProviderBinance: {
+			NewProvider: func(url string) types.Provider {
+				return binance.NewProvider(url)
+			},
+			Handler:  fixtureHandler("/api/v3/klines", conformanceBinanceChartResponse),
+			Ticker:   "BTCUSDT",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    `[]`,
+		},
+		types.ProviderCoinbase: {
+			NewProvider: func(url string) types.Provider {
+				return coinbase.NewProvider(url)
+			},
+			Handler:  fixtureHandler("/products/BTC-USD/candles", conformanceCoinbaseChartResponse),
+			Ticker:   "BTC-USD",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    `[]`,
+		},
+		types.ProviderKraken: {
+			NewProvider: func(url string) types.Provider {
+				return kraken.NewProvider(url)
+			},
+			Handler:  fixtureHandler("/0/public/OHLC", conformanceKrakenChartResponse),
+			Ticker:   "XBTUSD",
+			Interval: "1d",
+			From:     tm,
+			To:       tm.AddDate(0, 0, 14),
+			Location: time.UTC,
+			Empty:    `{"error":[],"result":{"XXBTZUSD":[],"last":0}}`,
+		},
+	}
+	for name, fixture := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			providertest.Run(t, fixture)
+		})
+	}
+}
@@ -16,100 +16,512 @@ package finance
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/regel/wsb/pkg/common"
 	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance/alphavantage"
+	"github.com/regel/wsb/pkg/finance/binance"
+	"github.com/regel/wsb/pkg/finance/coinbase"
 	"github.com/regel/wsb/pkg/finance/coingecko"
 	"github.com/regel/wsb/pkg/finance/iex"
+	"github.com/regel/wsb/pkg/finance/kraken"
+	"github.com/regel/wsb/pkg/finance/polygon"
+	"github.com/regel/wsb/pkg/finance/stooq"
 	"github.com/regel/wsb/pkg/finance/types"
 	"github.com/regel/wsb/pkg/finance/yahoo"
 	"golang.org/x/time/rate"
-	"net"
+	"io"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
 
-type Handler struct {
+const (
+	// Maximum number of tickers in a single quote request
+	quoteBatchMaxLen = 100
+)
+
+// source is a provider of the fallback chain with its own rate limit
+type source struct {
+	name     string
 	provider types.Provider
-	client   *http.Client
-	limiter  *rate.Limiter
+	// Provider settings changing the charts, part of the cache keys
+	options string
+	limiter *rate.Limiter
+	// Client whose requests, retries and pages included, wait for the limiter
+	client *http.Client
 }
 
-// NewHandler creates a handler
-func NewHandler(config config.Configuration) (*Handler, error) {
-	var limiter *rate.Limiter
+type Handler struct {
+	// Default providers in fallback order. Only the first one serves
+	// quotes, holders and searches.
+	sources []*source
+	// Providers of the tickers routed by the configuration
+	routes map[string][]*source
+	// Sources by provider name, shared by all the chains
+	named map[string]*source
+	cache *Cache
+	// File keeping the token buckets of the limiters between runs
+	statePath string
+	// Writer of debug traces, nil if --debug is not set
+	debug io.Writer
+}
+
+func newSource(name string, config config.Configuration) (*source, error) {
+	var limit string
+	var bursts int
 	var provider types.Provider
-	var netTransport = &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: 5 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 5 * time.Second,
-	}
-	var cli = &http.Client{
-		Transport: netTransport,
-	}
-	switch config.Provider {
+	switch name {
 	case types.ProviderYahoo:
-		// Yahoo Finance usage is capped at 2,000 requests/hour
-		limiter = rate.NewLimiter(rate.Every(time.Hour/2000), config.Bursts)
+		limit, bursts = config.YahooRateLimit, config.YahooBursts
 		provider = yahoo.NewProvider(config.YahooFinanceUrl, config.YahooFinanceQueryUrl)
 	case types.ProviderIEX:
-		limiter = rate.NewLimiter(rate.Every(time.Second/100), config.Bursts)
+		limit, bursts = config.IexCloudRateLimit, config.IexCloudBursts
 		provider = iex.NewProvider(config.IexCloudQueryUrl, config.IexCloudSecretToken)
 	case types.ProviderCoingecko:
-		limiter = rate.NewLimiter(rate.Every(time.Minute/50), config.Bursts)
+		limit, bursts = config.CoingeckoRateLimit, config.CoingeckoBursts
 		provider = coingecko.NewProvider(config.CoingeckoQueryUrl, config.CoingeckoSecretToken)
+	case types.ProviderStooq:
+		limit, bursts = config.StooqRateLimit, config.StooqBursts
+		provider = stooq.NewProvider(config.StooqQueryUrl)
+	case types.ProviderAlphaVantage:
+		limit, bursts = config.AlphaVantageRateLimit, config.AlphaVantageBursts
+		provider = alphavantage.NewProvider(config.AlphaVantageQueryUrl, config.AlphaVantageSecretToken)
+	case types.ProviderPolygon:
+		limit, bursts = config.PolygonRateLimit, config.PolygonBursts
+		provider = polygon.NewProvider(config.PolygonQueryUrl, config.PolygonSecretToken, !config.PolygonUnadjusted)
+	case types.ProviderBinance:
+		limit, bursts = config.BinanceRateLimit, config.BinanceBursts
+		provider = binance.NewProvider(config.BinanceQueryUrl)
+	case types.ProviderCoinbase:
+		limit, bursts = config.CoinbaseRateLimit, config.CoinbaseBursts
+		provider = coinbase.NewProvider(config.CoinbaseQueryUrl)
+	case types.ProviderKraken:
+		limit, bursts = config.KrakenRateLimit, config.KrakenBursts
+		provider = kraken.NewProvider(config.KrakenQueryUrl)
 	default:
 		panic("Unknown data source provider. Check configuration")
 	}
+	if limit == "" {
+		limit = defaultRateLimits[name]
+	}
+	every, err := ParseRateLimit(limit)
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring '%s' provider: %v", name, err)
+	}
+	if bursts <= 0 {
+		bursts = config.Bursts
+	}
+	if config.Replay != "" {
+		// Replayed calls are not throttled
+		every = rate.Inf
+	}
+	var options string
+	if p, ok := provider.(interface{ Options() string }); ok {
+		options = p.Options()
+	}
+	return &source{
+		name:     name,
+		provider: provider,
+		options:  options,
+		limiter:  rate.NewLimiter(every, bursts),
+	}, nil
+}
 
+// NewHandler creates a handler. The provider of the configuration is
+// a comma-separated list of providers tried in order to fetch charts.
+func NewHandler(config config.Configuration) (*Handler, error) {
+	cli, err := common.NewClient(common.ClientOptions{
+		DialTimeout:    config.DialTimeout,
+		RequestTimeout: config.RequestTimeout,
+		Proxy:          config.Proxy,
+		CACert:         config.CACert,
+		ClientCert:     config.ClientCert,
+		ClientKey:      config.ClientKey,
+		UserAgent:      config.UserAgent,
+		Debug:          config.Debug,
+		DebugDir:       config.DebugDir,
+		Record:         config.Record,
+		Replay:         config.Replay,
+		MaxRetries:     config.MaxRetries,
+		RetryMaxWait:   config.RetryMaxWait,
+	})
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]*source)
+	for _, name := range types.Providers {
+		s, err := newSource(name, config)
+		if err != nil {
+			return nil, err
+		}
+		s.client = common.WithLimiter(cli, s.limiter)
+		named[name] = s
+	}
+	if config.RateLimitState != "" {
+		state, err := loadRateLimitState(config.RateLimitState)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for name, s := range named {
+			if bucket, ok := state[name]; ok {
+				restoreTokens(s.limiter, bucket, now)
+			}
+		}
+	}
 	h := &Handler{
-		provider: provider,
-		client:   cli,
-		limiter:  limiter,
+		named:     named,
+		routes:    make(map[string][]*source),
+		statePath: config.RateLimitState,
+	}
+	if config.Debug {
+		h.debug = os.Stderr
+	}
+	h.sources, err = h.chain(config.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.sources) == 0 {
+		return nil, fmt.Errorf("No data source provider. Check configuration")
+	}
+	for ticker, providers := range config.Routes {
+		chain, err := h.chain(providers)
+		if err != nil {
+			return nil, fmt.Errorf("Error routing ticker '%s': %v", ticker, err)
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("No provider for ticker '%s'. Check routes configuration", ticker)
+		}
+		h.routes[routeKey(ticker)] = chain
+	}
+	// Recorded and replayed calls bypass the cache
+	if config.CacheDir != "" && !config.NoCache && config.Record == "" && config.Replay == "" {
+		h.cache = NewCache(config.CacheDir, config.CacheTTL, config.CacheCurrentTTL)
 	}
 	return h, nil
 }
 
+// Close saves the state of the rate limiters if a state file is configured
+func (h *Handler) Close() error {
+	if h.statePath == "" {
+		return nil
+	}
+	return saveRateLimitState(h.statePath, h.named, time.Now())
+}
+
+// chain returns the sources of a comma-separated list of providers
+func (h *Handler) chain(providers string) ([]*source, error) {
+	sources := make([]*source, 0)
+	for _, name := range strings.Split(providers, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		s, ok := h.named[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown data source provider '%s'. Check configuration", name)
+		}
+		sources = append(sources, s)
+	}
+	return sources, nil
+}
+
+// Provider returns the name of the first provider of the fallback chain
+func (h *Handler) Provider() string {
+	return h.sources[0].name
+}
+
 func (h *Handler) GetHolders(c context.Context, ticker string) (*types.HoldersBreakdown, *types.HoldersTable, *types.HoldersTable, error) {
-	err := h.limiter.Wait(c)
+	chain, symbol := h.route(ticker)
+	s := chain[0]
+	return s.provider.GetHolders(c, s.client, symbol)
+}
+
+// GetQuotes returns the latest quote of the tickers. Tickers are batched
+// in as few requests as possible to the first provider of each ticker.
+func (h *Handler) GetQuotes(c context.Context, tickers []string) ([]types.Quote, error) {
+	sources := make([]*source, 0)
+	symbols := make(map[*source][]string)
+	// Symbols of the tickers which are not qualified
+	plain := make(map[*source][]string)
+	qualified := make(map[*source][]*routeGroup)
+	for _, g := range h.group(tickers) {
+		s := g.sources[0]
+		if _, ok := symbols[s]; !ok {
+			sources = append(sources, s)
+		}
+		for _, symbol := range g.symbols {
+			if !containsFold(symbols[s], symbol) {
+				symbols[s] = append(symbols[s], symbol)
+			}
+		}
+		if g.qualified != nil {
+			qualified[s] = append(qualified[s], g)
+		} else {
+			plain[s] = append(plain[s], g.symbols...)
+		}
+	}
+	quotes := make([]types.Quote, 0)
+	for _, s := range sources {
+		out, err := h.getQuotes(c, s, symbols[s])
+		if err != nil {
+			return nil, err
+		}
+		// The quotes of qualified tickers keep the qualifier. A symbol
+		// selected with and without qualifier has a quote for each ticker.
+		for _, q := range out {
+			matches := make([]types.Quote, 0)
+			for _, g := range qualified[s] {
+				if ticker, ok := g.ticker(q.Ticker); ok {
+					match := q
+					match.Ticker = ticker
+					matches = append(matches, match)
+				}
+			}
+			if len(plain[s]) > 0 && (len(matches) == 0 || containsFold(plain[s], q.Ticker)) {
+				quotes = append(quotes, q)
+			}
+			quotes = append(quotes, matches...)
+		}
+	}
+	return quotes, nil
+}
+
+// containsFold returns true if the symbols contain symbol in any case
+func containsFold(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if strings.EqualFold(s, symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) getQuotes(c context.Context, s *source, tickers []string) ([]types.Quote, error) {
+	quotes := make([]types.Quote, 0)
+	for start := 0; start < len(tickers); start += quoteBatchMaxLen {
+		end := start + quoteBatchMaxLen
+		if end > len(tickers) {
+			end = len(tickers)
+		}
+		out, err := s.provider.GetQuotes(c, s.client, tickers[start:end])
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, out...)
+	}
+	return quotes, nil
+}
+
+func (h *Handler) SearchSymbols(c context.Context, query string) ([]types.Symbol, error) {
+	s := h.sources[0]
+	return s.provider.SearchSymbols(c, s.client, query)
+}
+
+// GetChart returns the chart of the ticker from the first provider
+// of the fallback chain that returns data
+func (h *Handler) GetChart(c context.Context, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	chain, symbol := h.route(ticker)
+	chart, err := h.getChart(c, chain, symbol, interval, from, to, nil)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err.Err
 	}
-	return h.provider.GetHolders(c, h.client, ticker)
+	if chart != nil && symbol != ticker {
+		qualify(chart, ticker)
+	}
+	return chart, nil
 }
 
 func (h *Handler) GetOhlc(c context.Context, ticker string, interval string, from time.Time, to time.Time) ([]types.Ohlc, error) {
-	var points []types.Ohlc
-	var err error
-	err = h.limiter.Wait(c)
+	chart, err := h.GetChart(c, ticker, interval, from, to)
 	if err != nil {
 		return nil, err
 	}
-	points, err = h.provider.GetOhlc(c, h.client, ticker, interval, from, to)
-	return points, err
+	return chart.Ohlc, nil
 }
 
-func (h *Handler) GetOhlcBatch(c context.Context, wg *sync.WaitGroup, chartChan chan *types.Chart, tickers []string, interval string, from time.Time, to time.Time) {
-	if h.provider.BatchSupported() {
-		h.provider.GetOhlcBatch(wg, chartChan, c, h.client, tickers, interval, from, to)
+// getCached returns the cached chart of the first provider having data for the ticker
+func (h *Handler) getCached(sources []*source, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, bool) {
+	if h.cache == nil {
+		return nil, false
+	}
+	for _, s := range sources {
+		if chart, ok := h.cache.Get(s.name, s.options, ticker, interval, from, to); ok && len(chart.Ohlc) > 0 {
+			return chart, true
+		}
+	}
+	return nil, false
+}
+
+// fetch returns the chart of the ticker served by one provider
+func (h *Handler) fetch(c context.Context, s *source, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	chart, err := s.provider.GetChart(c, s.client, ticker, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	chart.Provider = s.name
+	h.putCache(s, ticker, interval, from, to, chart)
+	return chart, nil
+}
+
+// getChart tries the providers in order until one of them returns data.
+// The chart is empty if no provider has data and none of them failed.
+// The errors of the providers tried before are reported with the errors
+// of these providers.
+func (h *Handler) getChart(c context.Context, sources []*source, ticker string, interval string, from time.Time, to time.Time, errs []error) (*types.Chart, *types.TickerError) {
+	if chart, ok := h.getCached(sources, ticker, interval, from, to); ok {
+		return chart, nil
+	}
+	var empty *types.Chart
+	var last string
+	for _, s := range sources {
+		chart, err := h.fetch(c, s, ticker, interval, from, to)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			last = s.name
+			if c.Err() != nil {
+				break
+			}
+			continue
+		}
+		if len(chart.Ohlc) == 0 {
+			if empty == nil {
+				empty = chart
+			}
+			continue
+		}
+		return chart, nil
+	}
+	if len(errs) == 0 {
+		return empty, nil
+	}
+	if len(errs) == 1 {
+		return nil, types.NewTickerError(ticker, last, errors.Unwrap(errs[0]))
+	}
+	return nil, types.NewTickerError(ticker, last, errors.Join(errs...))
+}
+
+func (h *Handler) putCache(s *source, ticker string, interval string, from time.Time, to time.Time, chart *types.Chart) {
+	if h.cache == nil {
 		return
 	}
-	for _, ticker := range tickers {
-		wg.Add(1)
-		go func(t string, window string, from time.Time, to time.Time) {
-			points, err := h.GetOhlc(c, t, window, from, to)
-			if err != nil {
-				wg.Done()
-				println(fmt.Sprintf("Error fetching '%s' data: %v", t, err))
-				return
+	// The chart is served even if it cannot be cached
+	if err := h.cache.Put(s.name, s.options, ticker, interval, from, to, chart); err != nil && h.debug != nil {
+		fmt.Fprintf(h.debug, "Error caching '%s' data: %v\n", ticker, err)
+	}
+}
+
+// GetOhlcBatch sends the chart of each ticker to chartChan. The charts of
+// tickers that could not be fetched have their Err set. Tickers are fetched
+// from their own providers and the charts are merged in the same channel.
+func (h *Handler) GetOhlcBatch(c context.Context, wg *sync.WaitGroup, chartChan chan *types.Chart, tickers []string, interval string, from time.Time, to time.Time) {
+	for _, g := range h.group(tickers) {
+		out := chartChan
+		groupWg := wg
+		if g.qualified != nil {
+			// Relay the charts of qualified tickers to set the qualifier
+			out = make(chan *types.Chart)
+			groupWg = &sync.WaitGroup{}
+		}
+		if g.sources[0].provider.BatchSupported() {
+			h.getOhlcBatch(c, groupWg, out, g.sources, g.symbols, interval, from, to)
+		} else {
+			for _, ticker := range g.symbols {
+				groupWg.Add(1)
+				go func(sources []*source, t string, window string, from time.Time, to time.Time) {
+					chart, err := h.getChart(c, sources, t, window, from, to, nil)
+					if err != nil {
+						chart = &types.Chart{
+							Ticker: t,
+							Err:    err,
+						}
+					}
+					out <- chart
+					groupWg.Done()
+				}(g.sources, ticker, interval, from, to)
 			}
-			chart := &types.Chart{
-				Ohlc:   points,
-				Ticker: t,
+		}
+		if g.qualified == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(g *routeGroup, relay chan *types.Chart, relayWg *sync.WaitGroup) {
+			go func() {
+				relayWg.Wait()
+				close(relay)
+			}()
+			for chart := range relay {
+				if ticker, ok := g.ticker(chart.Ticker); ok {
+					qualify(chart, ticker)
+				}
+				chartChan <- chart
 			}
+			wg.Done()
+		}(g, out, groupWg)
+	}
+}
+
+// getOhlcBatch serves the cached charts and fetches the other tickers
+// with batch requests to the first provider. Tickers without data are
+// fetched from the next providers.
+func (h *Handler) getOhlcBatch(c context.Context, wg *sync.WaitGroup, chartChan chan *types.Chart, sources []*source, tickers []string, interval string, from time.Time, to time.Time) {
+	primary := sources[0]
+	missing := make([]string, 0)
+	for _, ticker := range tickers {
+		chart, ok := h.getCached(sources, ticker, interval, from, to)
+		if !ok {
+			missing = append(missing, ticker)
+			continue
+		}
+		wg.Add(1)
+		go func(chart *types.Chart) {
 			chartChan <- chart
 			wg.Done()
-		}(ticker, interval, from, to)
+		}(chart)
 	}
+	if len(missing) == 0 {
+		return
+	}
+	// Relay the batch charts to store them in the cache and fall back
+	// to the next providers
+	var relayWg sync.WaitGroup
+	relay := make(chan *types.Chart)
+	primary.provider.GetOhlcBatch(&relayWg, relay, c, primary.client, missing, interval, from, to)
+	wg.Add(1)
+	go func() {
+		go func() {
+			relayWg.Wait()
+			close(relay)
+		}()
+		for chart := range relay {
+			if chart.Err == nil {
+				chart.Provider = primary.name
+				h.putCache(primary, chart.Ticker, interval, from, to, chart)
+			}
+			if len(sources) == 1 || (chart.Err == nil && len(chart.Ohlc) > 0) {
+				chartChan <- chart
+				continue
+			}
+			wg.Add(1)
+			go func(chart *types.Chart) {
+				var errs []error
+				if chart.Err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", primary.name, chart.Err.Err))
+				}
+				next, err := h.getChart(c, sources[1:], chart.Ticker, interval, from, to, errs)
+				if err != nil {
+					chart = &types.Chart{Ticker: chart.Ticker, Err: err}
+				} else if len(next.Ohlc) > 0 || chart.Err != nil {
+					chart = next
+				}
+				chartChan <- chart
+				wg.Done()
+			}(chart)
+		}
+		wg.Done()
+	}()
 }
@@ -2,18 +2,30 @@ package finance
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/regel/wsb/pkg/common"
 	"github.com/regel/wsb/pkg/config"
+	"github.com/regel/wsb/pkg/finance/binance"
+	"github.com/regel/wsb/pkg/finance/kraken"
+	"github.com/regel/wsb/pkg/finance/stooq"
 	"github.com/regel/wsb/pkg/finance/types"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 )
 
+// Fixtures are bodies of provider responses. Update them with the body of
+// the cassettes recorded by 'wsb --record <dir>' with a pinned time range.
 const sampleChartResponse = `
 {
 	"chart": {
@@ -125,6 +137,55 @@ const sampleChartNoContent = `
 }
 `
 
+const sampleChartEventsResponse = `
+{
+	"chart": {
+		"result": [{
+			"meta": {
+				"currency": "USD",
+				"symbol": "AAPL",
+				"exchangeName": "NMS",
+				"instrumentType": "EQUITY",
+				"timezone": "EDT",
+				"exchangeTimezoneName": "America/New_York",
+				"dataGranularity": "1d",
+				"range": ""
+			},
+			"timestamp": [1596807000, 1598880600],
+			"events": {
+				"splits": {
+					"1598880600": {
+						"date": 1598880600,
+						"numerator": 4,
+						"denominator": 1,
+						"splitRatio": "4:1"
+					}
+				},
+				"dividends": {
+					"1596807000": {
+						"amount": 0.205,
+						"date": 1596807000
+					}
+				}
+			},
+			"indicators": {
+				"quote": [{
+					"close": [111.1125030517578, 129.0399932861328],
+					"high": [113.67500305175781, 131.0],
+					"open": [113.20500183105469, 127.58000183105469],
+					"volume": [198045600, 225702700],
+					"low": [110.29250335693359, 126.0]
+				}],
+				"adjclose": [{
+					"adjclose": [109.38579559326172, 127.24864196777344]
+				}]
+			}
+		}],
+		"error": null
+	}
+}
+`
+
 const sampleHoldersResponse = `
 <html>
   <table>
@@ -773,6 +834,53 @@ func TestYahooChartBatchResponse(t *testing.T) {
 	require.InDelta(t, expected.Close, out.Ohlc[0].Close, 0.01, "Close must be the same")
 }
 
+func TestYahooChartEvents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			require.Equal(t, "div,split", r.URL.Query().Get("events"))
+			rsp = sampleChartEventsResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Tickers:              []string{"AAPL"},
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from := time.Unix(1596807000, 0)
+	to := time.Unix(1598880600, 0)
+	out, err := n.GetChart(context, "AAPL", "1d", from, to)
+	require.NoError(t, err)
+
+	require.Equal(t, "AAPL", out.Ticker, "Ticker must be the same")
+	require.Equal(t, 2, len(out.Ohlc), "Should contain two items")
+	require.InDelta(t, 109.38, out.Ohlc[0].AdjClose, 0.01, "AdjClose must be the same")
+	require.InDelta(t, 127.24, out.Ohlc[1].AdjClose, 0.01, "AdjClose must be the same")
+
+	require.Equal(t, 1, len(out.Dividends), "Should contain one dividend")
+	require.True(t, time.Unix(1596807000, 0).Equal(out.Dividends[0].Timestamp))
+	require.InDelta(t, 0.205, out.Dividends[0].Amount, 0.0001, "Amount must be the same")
+
+	require.Equal(t, 1, len(out.Splits), "Should contain one split")
+	require.True(t, time.Unix(1598880600, 0).Equal(out.Splits[0].Timestamp))
+	require.Equal(t, 4.0, out.Splits[0].Ratio(), "Ratio must be the same")
+}
+
 func TestYahooChartNoContent(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var rsp string
@@ -858,6 +966,35 @@ func TestIexCloudChartResponse(t *testing.T) {
 	require.InDelta(t, expected.Close, out[0].Close, 0.01, "Close must be the same")
 }
 
+func TestIexCloudChartIntraday(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/stock/market/batch" {
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		fmt.Fprintln(w, `{"AAPL":{"chart":[{"date":"2021-03-04","minute":"09:30","open":121.75,"high":121.8,"low":121.5,"close":121.6,"volume":1000}]}}`)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:            "iex",
+		IexCloudQueryUrl:    ts.URL,
+		IexCloudSecretToken: "SECRET_TOKEN",
+		DialTimeout:         time.Second,
+		Bursts:              1,
+		Debug:               false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	out, err := n.GetOhlc(context, "AAPL", "1d", tm, tm.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(out), "Should contain one item")
+	require.InDelta(t, 121.6, out[0].AdjClose, 0.01, "AdjClose must be the close of intraday bars")
+}
+
 func TestIexCloudChartBatchResponse(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var rsp string
@@ -1039,3 +1176,1713 @@ func TestCoinGeckoChartErrorResponse(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, out)
 }
+
+const sampleQuoteResponse = `
+{
+	"quoteResponse": {
+		"result": [{
+			"language": "en-US",
+			"region": "US",
+			"quoteType": "EQUITY",
+			"currency": "USD",
+			"marketState": "POSTPOST",
+			"regularMarketChangePercent": 0.7,
+			"regularMarketPreviousClose": 122.15,
+			"bid": 122.9,
+			"ask": 123.05,
+			"regularMarketPrice": 123.0,
+			"regularMarketTime": 1617307203,
+			"regularMarketChange": 0.85,
+			"regularMarketOpen": 123.66,
+			"regularMarketVolume": 75089134,
+			"exchange": "NMS",
+			"symbol": "AAPL"
+		}],
+		"error": null
+	}
+}
+`
+
+const sampleIexQuoteResponse = `
+{
+	"AAPL": {
+		"quote": {
+			"symbol": "AAPL",
+			"currency": "USD",
+			"latestPrice": 123.0,
+			"latestUpdate": 1617307203000,
+			"iexBidPrice": 122.9,
+			"iexAskPrice": 123.05,
+			"open": 123.66,
+			"previousClose": 122.15,
+			"change": 0.85,
+			"changePercent": 0.00696,
+			"latestVolume": 75089134,
+			"isUSMarketOpen": true
+		}
+	}
+}
+`
+
+const sampleCoingeckoQuoteResponse = `
+{
+	"bitcoin": {
+		"usd": 58668.63,
+		"usd_24h_change": 5.0,
+		"last_updated_at": 1617307203
+	}
+}
+`
+
+func TestYahooQuoteResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v7/finance/quote" {
+			require.Equal(t, "AAPL", r.URL.Query().Get("symbols"))
+			rsp = sampleQuoteResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Tickers:              []string{"AAPL"},
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetQuotes(context, []string{"AAPL"})
+	require.NoError(t, err)
+
+	expected := types.Quote{
+		Ticker:        "AAPL",
+		Timestamp:     time.Unix(1617307203, 0),
+		Currency:      "USD",
+		Price:         123.0,
+		Bid:           122.9,
+		Ask:           123.05,
+		Open:          123.66,
+		PreviousClose: 122.15,
+		Change:        0.85,
+		ChangePercent: 0.7,
+		Volume:        75089134,
+		MarketState:   types.MarketStatePost,
+	}
+	require.Equal(t, []types.Quote{expected}, out)
+}
+
+func TestIexCloudQuoteResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/stock/market/batch" {
+			require.Equal(t, "quote", r.URL.Query().Get("types"))
+			rsp = sampleIexQuoteResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:            "iex",
+		IexCloudQueryUrl:    ts.URL,
+		IexCloudSecretToken: "SECRET_TOKEN",
+		DialTimeout:         time.Second,
+		Bursts:              1,
+		Tickers:             []string{"AAPL"},
+		Debug:               false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetQuotes(context, []string{"AAPL"})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(out), "Should contain one item")
+	require.Equal(t, "AAPL", out[0].Ticker, "Ticker must be the same")
+	require.True(t, time.Unix(1617307203, 0).Equal(out[0].Timestamp))
+	require.InDelta(t, 123.0, out[0].Price, 0.01, "Price must be the same")
+	require.InDelta(t, 122.9, out[0].Bid, 0.01, "Bid must be the same")
+	require.InDelta(t, 123.05, out[0].Ask, 0.01, "Ask must be the same")
+	require.InDelta(t, 0.696, out[0].ChangePercent, 0.001, "ChangePercent must be in %")
+	require.Equal(t, types.MarketStateRegular, out[0].MarketState)
+}
+
+func TestCoinGeckoQuoteResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/api/v3/simple/price" {
+			require.Equal(t, "bitcoin", r.URL.Query().Get("ids"))
+			rsp = sampleCoingeckoQuoteResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:          "coingecko",
+		CoingeckoQueryUrl: ts.URL,
+		DialTimeout:       time.Second,
+		Bursts:            1,
+		Tickers:           []string{"bitcoin"},
+		Debug:             false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetQuotes(context, []string{"bitcoin"})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(out), "Should contain one item")
+	require.Equal(t, "bitcoin", out[0].Ticker, "Ticker must be the same")
+	require.InDelta(t, 58668.63, out[0].Price, 0.01, "Price must be the same")
+	require.InDelta(t, 55874.88, out[0].PreviousClose, 0.01, "PreviousClose must be the same")
+	require.Zero(t, out[0].Open, "Open must not be set")
+	require.InDelta(t, 5.0, out[0].ChangePercent, 0.01, "ChangePercent must be the same")
+}
+
+func TestCoinGeckoQuoteTotalLoss(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/simple/price" {
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		fmt.Fprintln(w, `{"terra-luna":{"usd":0.0,"usd_24h_change":-100.0,"last_updated_at":1617307203}}`)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:          "coingecko",
+		CoingeckoQueryUrl: ts.URL,
+		DialTimeout:       time.Second,
+		Bursts:            1,
+		Debug:             false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetQuotes(context, []string{"terra-luna"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(out), "Should contain one item")
+	require.Zero(t, out[0].PreviousClose, "PreviousClose must not be set")
+	require.Zero(t, out[0].Change, "Change must not be set")
+	require.InDelta(t, -100.0, out[0].ChangePercent, 0.01, "ChangePercent must be the same")
+}
+
+const sampleSearchResponse = `
+{
+	"explains": [],
+	"count": 1,
+	"quotes": [{
+		"exchange": "NMS",
+		"shortname": "Apple Inc.",
+		"quoteType": "EQUITY",
+		"symbol": "AAPL",
+		"index": "quotes",
+		"score": 1264400,
+		"typeDisp": "Equity",
+		"longname": "Apple Inc.",
+		"exchDisp": "NASDAQ",
+		"isYahooFinance": true
+	}],
+	"news": []
+}
+`
+
+const sampleIexSearchResponse = `
+[
+	{
+		"symbol": "AAPL",
+		"cik": "320193",
+		"securityName": "Apple Inc",
+		"securityType": "cs",
+		"region": "US",
+		"exchange": "XNAS",
+		"sector": "Electronic Technology"
+	}
+]
+`
+
+const sampleCoingeckoSearchResponse = `
+{
+	"coins": [{
+		"id": "avalanche-2",
+		"name": "Avalanche",
+		"api_symbol": "avalanche-2",
+		"symbol": "AVAX",
+		"market_cap_rank": 11
+	}],
+	"exchanges": [],
+	"categories": []
+}
+`
+
+func TestYahooSearchResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/finance/search" {
+			require.Equal(t, "apple", r.URL.Query().Get("q"))
+			rsp = sampleSearchResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.SearchSymbols(context, "apple")
+	require.NoError(t, err)
+
+	expected := []types.Symbol{
+		{
+			Symbol:   "AAPL",
+			Name:     "Apple Inc.",
+			Exchange: "NMS",
+			Type:     "EQUITY",
+			Id:       "AAPL",
+		},
+	}
+	require.Equal(t, expected, out)
+}
+
+func TestIexCloudSearchResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/search/apple" {
+			rsp = sampleIexSearchResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:            "iex",
+		IexCloudQueryUrl:    ts.URL,
+		IexCloudSecretToken: "SECRET_TOKEN",
+		DialTimeout:         time.Second,
+		Bursts:              1,
+		Debug:               false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.SearchSymbols(context, "apple")
+	require.NoError(t, err)
+
+	expected := []types.Symbol{
+		{
+			Symbol:   "AAPL",
+			Name:     "Apple Inc",
+			Exchange: "XNAS",
+			Type:     "cs",
+			Id:       "AAPL",
+		},
+	}
+	require.Equal(t, expected, out)
+}
+
+func TestCoinGeckoSearchResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/api/v3/search" {
+			require.Equal(t, "avalanche", r.URL.Query().Get("query"))
+			rsp = sampleCoingeckoSearchResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:          "coingecko",
+		CoingeckoQueryUrl: ts.URL,
+		DialTimeout:       time.Second,
+		Bursts:            1,
+		Debug:             false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.SearchSymbols(context, "avalanche")
+	require.NoError(t, err)
+
+	expected := []types.Symbol{
+		{
+			Symbol: "AVAX",
+			Name:   "Avalanche",
+			Type:   "CRYPTOCURRENCY",
+			Id:     "avalanche-2",
+		},
+	}
+	require.Equal(t, expected, out)
+}
+
+func TestYahooChartCache(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			requests++
+			rsp = sampleChartEventsResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Tickers:              []string{"AAPL"},
+		Debug:                false,
+		CacheDir:             t.TempDir(),
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from := time.Unix(1596807000, 0)
+	to := time.Unix(1598880600, 0)
+	out, err := n.GetChart(context, "AAPL", "1d", from, to)
+	require.NoError(t, err)
+	cached, err := n.GetChart(context, "AAPL", "1d", from, to)
+	require.NoError(t, err)
+	require.Equal(t, 1, requests, "Second chart must be read from the cache")
+	require.Equal(t, out.Ticker, cached.Ticker, "Ticker must be the same")
+	require.Equal(t, len(out.Ohlc), len(cached.Ohlc), "Should contain the same items")
+	require.True(t, out.Ohlc[1].Timestamp.Equal(cached.Ohlc[1].Timestamp))
+	require.Equal(t, out.Ohlc[1].AdjClose, cached.Ohlc[1].AdjClose, "AdjClose must be the same")
+	require.Equal(t, out.Splits[0].Ratio(), cached.Splits[0].Ratio(), "Ratio must be the same")
+
+	configuration.NoCache = true
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	_, err = n.GetChart(context, "AAPL", "1d", from, to)
+	require.NoError(t, err)
+	require.Equal(t, 2, requests, "Cache must be disabled")
+}
+
+func TestIexCloudChartReplay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/stock/market/batch" {
+			rsp = sampleIexChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+
+	context := context.Background()
+	cassettes := t.TempDir()
+	configuration := &config.Configuration{
+		Provider:            "iex",
+		IexCloudQueryUrl:    ts.URL,
+		IexCloudSecretToken: "SECRET_TOKEN",
+		DialTimeout:         time.Second,
+		Bursts:              1,
+		Tickers:             []string{"AAPL"},
+		Debug:               false,
+		Record:              cassettes,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	recorded, err := n.GetChart(context, "AAPL", "1d", tm, tm)
+	require.NoError(t, err)
+	ts.Close()
+
+	// Secret tokens are not recorded
+	paths, err := filepath.Glob(filepath.Join(cassettes, "*", "*.json"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(paths), "Should contain one cassette")
+	b, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "SECRET_TOKEN")
+
+	configuration.Record = ""
+	configuration.Replay = cassettes
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	replayed, err := n.GetChart(context, "AAPL", "1d", tm, tm)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(replayed.Ohlc), "Should contain one item")
+	require.Equal(t, recorded.Ohlc, replayed.Ohlc, "Ohlc must be the same")
+
+	_, err = n.GetChart(context, "GME", "1d", tm, tm)
+	require.Error(t, err, "Calls without a cassette must fail")
+}
+
+func TestYahooChartRetry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			requests++
+			if requests == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			rsp = sampleChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		MaxRetries:           1,
+		RetryMaxWait:         time.Second,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetChart(context, "AAPL", "1d", time.Unix(1617307203, 0), time.Unix(1617307203, 0))
+	require.NoError(t, err)
+	require.Equal(t, 2, requests, "Request must be retried")
+	require.Equal(t, "AAPL", out.Ticker, "Ticker must be the same")
+	require.Equal(t, 1, len(out.Ohlc), "Should contain one item")
+}
+
+func TestYahooChartBatchErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			rsp = sampleChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else if r.URL.Path == "/v8/finance/chart/UNKNOWN" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               2,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from := time.Unix(1617307203, 0)
+	to := time.Unix(1617307203, 0)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL", "UNKNOWN"}, "1d", from, to)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+
+	charts := make(map[string]*types.Chart)
+	for chart := range chartChan {
+		charts[chart.Ticker] = chart
+	}
+	require.Equal(t, 2, len(charts), "Should contain two charts")
+	require.Nil(t, charts["AAPL"].Err)
+	require.Equal(t, 1, len(charts["AAPL"].Ohlc), "Should contain one item")
+
+	out := charts["UNKNOWN"].Err
+	require.NotNil(t, out)
+	require.Equal(t, "UNKNOWN", out.Ticker, "Ticker must be the same")
+	require.Equal(t, "yahoo", out.Provider, "Provider must be the same")
+	require.Equal(t, http.StatusNotFound, out.StatusCode, "Status must be the same")
+	require.Empty(t, charts["UNKNOWN"].Ohlc)
+}
+
+func TestIexCloudChartBatchErrors(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/stock/market/batch" {
+			rsp = sampleIexChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:            "iex",
+		IexCloudQueryUrl:    ts.URL,
+		IexCloudSecretToken: "SECRET_TOKEN",
+		DialTimeout:         time.Second,
+		Bursts:              1,
+		Debug:               false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	batch := func() map[string]*types.Chart {
+		var wg sync.WaitGroup
+		chartChan := make(chan *types.Chart)
+		n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL", "UNKNOWN"}, "1d", tm, tm)
+		go func() {
+			wg.Wait()
+			close(chartChan)
+		}()
+		charts := make(map[string]*types.Chart)
+		for chart := range chartChan {
+			charts[chart.Ticker] = chart
+		}
+		return charts
+	}
+
+	charts := batch()
+	require.Equal(t, 2, len(charts), "Should contain two charts")
+	require.Nil(t, charts["AAPL"].Err)
+	require.NotNil(t, charts["UNKNOWN"].Err, "Unknown symbols must be reported")
+	require.Equal(t, "iex", charts["UNKNOWN"].Err.Provider, "Provider must be the same")
+
+	status = http.StatusPaymentRequired
+	charts = batch()
+	require.Equal(t, 2, len(charts), "Should contain two charts")
+	for _, ticker := range []string{"AAPL", "UNKNOWN"} {
+		require.NotNil(t, charts[ticker].Err)
+		require.Equal(t, http.StatusPaymentRequired, charts[ticker].Err.StatusCode, "Status must be the same")
+	}
+}
+
+func TestProviderFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if r.URL.Path == "/v1/stock/market/batch" {
+			rsp = sampleIexChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo, iex",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		IexCloudQueryUrl:     ts.URL,
+		IexCloudSecretToken:  "SECRET_TOKEN",
+		DialTimeout:          time.Second,
+		Bursts:               2,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+	require.Equal(t, "yahoo", n.Provider(), "Provider must be the first of the chain")
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	out, err := n.GetChart(context, "AAPL", "1d", tm, tm)
+	require.NoError(t, err)
+	require.Equal(t, "iex", out.Provider, "Chart must be served by the fallback provider")
+	require.Equal(t, 1, len(out.Ohlc), "Should contain one item")
+
+	configuration.Provider = "yahoo,yahoo"
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL"}, "1d", tm, tm)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+	failed := <-chartChan
+	require.NotNil(t, failed.Err)
+	require.Equal(t, http.StatusInternalServerError, failed.Err.StatusCode, "Status must be the same")
+	require.Contains(t, failed.Err.Error(), "yahoo: Non-OK HTTP status: 500")
+}
+
+func TestProviderBatchFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/stock/market/batch" {
+			rsp = sampleIexChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else if r.URL.Path == "/v8/finance/chart/GME" {
+			rsp = strings.ReplaceAll(sampleChartResponse, "AAPL", "GME")
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "iex,yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		IexCloudQueryUrl:     ts.URL,
+		IexCloudSecretToken:  "SECRET_TOKEN",
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from, _ := time.Parse("2006-01-02", "2021-03-04")
+	to := time.Unix(1617307203, 0)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL", "GME"}, "1d", from, to)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+
+	charts := make(map[string]*types.Chart)
+	for chart := range chartChan {
+		charts[chart.Ticker] = chart
+	}
+	require.Equal(t, 2, len(charts), "Should contain two charts")
+	require.Nil(t, charts["AAPL"].Err)
+	require.Equal(t, "iex", charts["AAPL"].Provider, "Provider must be the same")
+	require.Nil(t, charts["GME"].Err)
+	require.Equal(t, "yahoo", charts["GME"].Provider, "Provider must be the same")
+	require.Equal(t, 1, len(charts["GME"].Ohlc), "Should contain one item")
+}
+
+func TestProviderFallbackEmpty(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if strings.HasPrefix(r.URL.Path, "/v2/aggs/") {
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, `{"status":"OK","resultsCount":0,"results":[]}`)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo,polygon",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		PolygonQueryUrl:      ts.URL,
+		PolygonSecretToken:   "SECRET_TOKEN",
+		PolygonRateLimit:     "100/s",
+		DialTimeout:          time.Second,
+		Bursts:               1,
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	_, err = n.GetChart(context, "AAPL", "1d", tm, tm)
+	require.Error(t, err, "Errors must be reported when no provider has data")
+	require.Contains(t, err.Error(), "Non-OK HTTP status: 500")
+
+	configuration.Provider = "polygon,yahoo"
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL"}, "1d", tm, tm)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+	failed := <-chartChan
+	require.NotNil(t, failed.Err, "Errors of the fallback provider must be reported")
+	require.Equal(t, "AAPL", failed.Err.Ticker, "Ticker must be the same")
+	require.Equal(t, "yahoo", failed.Err.Provider, "Provider must be the same")
+	require.Equal(t, http.StatusInternalServerError, failed.Err.StatusCode, "Status must be the same")
+}
+
+func TestSplitTicker(t *testing.T) {
+	provider, symbol := SplitTicker("coingecko:bitcoin")
+	require.Equal(t, "coingecko", provider)
+	require.Equal(t, "bitcoin", symbol)
+
+	provider, symbol = SplitTicker("AAPL")
+	require.Empty(t, provider)
+	require.Equal(t, "AAPL", symbol)
+
+	provider, symbol = SplitTicker("unknown:AAPL")
+	require.Empty(t, provider, "Only known providers can qualify tickers")
+	require.Equal(t, "unknown:AAPL", symbol)
+}
+
+func TestTickerRoutes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v8/finance/chart/AAPL" {
+			rsp = sampleChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else if r.URL.Path == "/api/v3/coins/bitcoin/ohlc" || r.URL.Path == "/api/v3/coins/cardano/ohlc" {
+			rsp = sampleCoingeckoChartResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		CoingeckoQueryUrl:    ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               2,
+		Routes:               map[string]string{"cardano": "coingecko"},
+		Debug:                false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+	require.Equal(t, "yahoo", n.ProviderOf("AAPL"), "Provider must be the same")
+	require.Equal(t, "coingecko", n.ProviderOf("coingecko:bitcoin"), "Provider must be the same")
+	require.Equal(t, "coingecko", n.ProviderOf("cardano"), "Provider must be the same")
+
+	from, _ := time.Parse("2006-01-02", "2021-03-04")
+	to := time.Unix(1617307203, 0)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL", "coingecko:bitcoin", "cardano"}, "1d", from, to)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+
+	charts := make(map[string]*types.Chart)
+	for chart := range chartChan {
+		charts[chart.Ticker] = chart
+	}
+	require.Equal(t, 3, len(charts), "Should contain three charts")
+	require.Equal(t, "yahoo", charts["AAPL"].Provider, "Provider must be the same")
+	require.Equal(t, "coingecko", charts["coingecko:bitcoin"].Provider, "Provider must be the same")
+	require.Equal(t, "coingecko:bitcoin", charts["coingecko:bitcoin"].Ohlc[0].Ticker, "Ticker must keep the qualifier")
+	require.Equal(t, "coingecko", charts["cardano"].Provider, "Provider must be the same")
+	for _, chart := range charts {
+		require.Nil(t, chart.Err)
+		require.Equal(t, 1, len(chart.Ohlc), "Should contain one item")
+	}
+}
+
+func TestTickerRoutesConfigFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v1/stock/market/batch" && r.URL.Query().Get("symbols") == "GME" {
+			rsp = strings.ReplaceAll(sampleIexChartResponse, "AAPL", "GME")
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	cfgFile := filepath.Join(t.TempDir(), "wsb.yaml")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(fmt.Sprintf(`provider: yahoo
+iex-cloud-query-url: %s
+iex-cloud-secret-token: SECRET_TOKEN
+no-cache: true
+bursts: 1
+routes:
+  GME: iex
+`, ts.URL)), 0644))
+	configuration, err := config.LoadConfiguration(cfgFile, &cobra.Command{}, false)
+	require.NoError(t, err)
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+	require.Equal(t, "iex", n.ProviderOf("GME"), "Routes must not be case sensitive")
+	require.Equal(t, "iex", n.ProviderOf("gme"), "Routes must not be case sensitive")
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-04")
+	chart, err := n.GetChart(context.Background(), "GME", "1d", tm, tm)
+	require.NoError(t, err)
+	require.Equal(t, "iex", chart.Provider, "Provider must be the same")
+	require.Equal(t, 1, len(chart.Ohlc), "Should contain one item")
+}
+
+func TestTickerRoutesUnknownProvider(t *testing.T) {
+	configuration := &config.Configuration{
+		Provider: "yahoo",
+		Routes:   map[string]string{"gme": "iex,unknown"},
+	}
+	_, err := NewHandler(*configuration)
+	require.Error(t, err, "Unknown providers must fail")
+
+	configuration = &config.Configuration{Provider: "unknown"}
+	_, err = NewHandler(*configuration)
+	require.Error(t, err, "Unknown providers must fail")
+}
+
+func TestQualifiedQuotes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		if r.URL.Path == "/v7/finance/quote" {
+			rsp = sampleQuoteResponse
+			w.Header()["Content-Type"] = []string{"application/json"}
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	configuration := &config.Configuration{
+		Provider:             "yahoo",
+		YahooFinanceUrl:      ts.URL,
+		YahooFinanceQueryUrl: ts.URL,
+		DialTimeout:          time.Second,
+		Bursts:               2,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	out, err := n.GetQuotes(context.Background(), []string{"AAPL", "yahoo:AAPL"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(out), "Should contain a quote for each ticker")
+	require.Equal(t, "AAPL", out[0].Ticker, "Ticker must be the same")
+	require.Equal(t, "yahoo:AAPL", out[1].Ticker, "Ticker must keep the qualifier")
+}
+
+const sampleStooqChartResponse = `Date,Open,High,Low,Close,Volume
+2021-03-01,123.75,127.93,122.79,127.79,116307892
+2021-03-08,120.93,121.0,116.21,116.36,154376610
+`
+
+func TestStooqChartResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		query := r.URL.Query()
+		if r.URL.Path == "/q/d/l/" && query.Get("s") == "aapl.us" && query.Get("i") == "w" {
+			require.Equal(t, "20210301", query.Get("d1"), "From must be the same")
+			require.Equal(t, "20210331", query.Get("d2"), "To must be the same")
+			rsp = sampleStooqChartResponse
+		} else if r.URL.Path == "/q/d/l/" {
+			rsp = "No data"
+		} else {
+			panic("Cannot handle request")
+		}
+
+		fmt.Fprint(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:      "stooq",
+		StooqQueryUrl: ts.URL,
+		DialTimeout:   time.Second,
+		Bursts:        3,
+		Tickers:       []string{"AAPL"},
+		Debug:         false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-08")
+	expected := types.Ohlc{
+		Ticker:    "AAPL",
+		Timestamp: tm,
+		Open:      120.93,
+		High:      121.0,
+		Low:       116.21,
+		Close:     116.36,
+		Volume:    154376610,
+	}
+
+	from, _ := time.Parse("2006-01-02", "2021-03-01")
+	to, _ := time.Parse("2006-01-02", "2021-03-31")
+	chart, err := n.GetChart(context, "AAPL", "1wk", from, to)
+	require.NoError(t, err)
+	require.Equal(t, types.ProviderStooq, chart.Provider, "Provider must be the same")
+	require.Equal(t, types.AdjustmentAll, chart.Adjustment, "Adjustment must be the same")
+
+	out := chart.Ohlc
+	require.Equal(t, 2, len(out), "Should contain two items")
+	require.Equal(t, expected.Ticker, out[1].Ticker, "Ticker must be the same")
+	require.True(t, expected.Timestamp.Equal(out[1].Timestamp), "Timestamp must be the same")
+	require.Equal(t, expected.Volume, out[1].Volume, "Volume must be the same")
+	require.InDelta(t, expected.Open, out[1].Open, 0.01, "Open must be the same")
+	require.InDelta(t, expected.High, out[1].High, 0.01, "High must be the same")
+	require.InDelta(t, expected.Low, out[1].Low, 0.01, "Low must be the same")
+	require.InDelta(t, expected.Close, out[1].Close, 0.01, "Close must be the same")
+
+	// Unknown symbols have no data
+	out, err = n.GetOhlc(context, "UNKNOWN", "1d", from, to)
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	_, err = n.GetOhlc(context, "AAPL", "1m", from, to)
+	require.Error(t, err, "Intraday intervals must fail")
+}
+
+func TestStooqSymbol(t *testing.T) {
+	tests := map[string]string{
+		"AAPL":    "aapl.us",
+		"aapl":    "aapl.us",
+		"VOD.UK":  "vod.uk",
+		"VOD.L":   "vod.uk",
+		"SAP.DE":  "sap.de",
+		"7203.T":  "7203.jp",
+		"^SPX":    "^spx",
+		"BRK-B":   "brk-b.us",
+		"BRK.B":   "brk-b.us",
+		"AAPL.US": "aapl.us",
+	}
+	for ticker, expected := range tests {
+		require.Equal(t, expected, stooq.Symbol(ticker), "Symbol must be the same")
+	}
+}
+
+const sampleAlphaVantageDailyResponse = `
+{
+    "Meta Data": {
+        "1. Information": "Daily Time Series with Splits and Dividend Events",
+        "2. Symbol": "AAPL",
+        "3. Last Refreshed": "2020-08-31",
+        "4. Output Size": "Full size",
+        "5. Time Zone": "US/Eastern"
+    },
+    "Time Series (Daily)": {
+        "2020-08-31": {
+            "1. open": "127.58",
+            "2. high": "131.0",
+            "3. low": "126.0",
+            "4. close": "129.04",
+            "5. adjusted close": "127.2486",
+            "6. volume": "225702700",
+            "7. dividend amount": "0.0000",
+            "8. split coefficient": "4.0"
+        },
+        "2020-08-07": {
+            "1. open": "452.82",
+            "2. high": "454.7",
+            "3. low": "441.17",
+            "4. close": "444.45",
+            "5. adjusted close": "109.3858",
+            "6. volume": "49511403",
+            "7. dividend amount": "0.8200",
+            "8. split coefficient": "1.0"
+        }
+    }
+}
+`
+
+const sampleAlphaVantageIntradayResponse = `
+{
+    "Meta Data": {
+        "1. Information": "Intraday (5min) open, high, low, close prices and volume",
+        "2. Symbol": "AAPL",
+        "3. Last Refreshed": "2021-03-05 20:00:00",
+        "4. Interval": "5min",
+        "5. Output Size": "Full size",
+        "6. Time Zone": "US/Eastern"
+    },
+    "Time Series (5min)": {
+        "2021-03-05 09:35:00": {
+            "1. open": "120.98",
+            "2. high": "121.5",
+            "3. low": "120.2",
+            "4. close": "121.1",
+            "5. volume": "1523001"
+        }
+    }
+}
+`
+
+const sampleAlphaVantageFxResponse = `
+{
+    "Meta Data": {
+        "1. Information": "Forex Daily Prices (open, high, low, close)",
+        "2. From Symbol": "EUR",
+        "3. To Symbol": "USD",
+        "4. Output Size": "Full size",
+        "5. Last Refreshed": "2021-03-05 21:55:00",
+        "6. Time Zone": "UTC"
+    },
+    "Time Series FX (Daily)": {
+        "2021-03-05": {
+            "1. open": "1.19680",
+            "2. high": "1.19740",
+            "3. low": "1.18930",
+            "4. close": "1.19160"
+        }
+    }
+}
+`
+
+func TestAlphaVantageChartResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		query := r.URL.Query()
+		require.Equal(t, "SECRET_TOKEN", query.Get("apikey"), "Token must be the same")
+		switch {
+		case r.URL.Path == "/query" && query.Get("function") == "TIME_SERIES_DAILY_ADJUSTED" && query.Get("symbol") == "AAPL":
+			rsp = sampleAlphaVantageDailyResponse
+		case r.URL.Path == "/query" && query.Get("function") == "TIME_SERIES_INTRADAY" && query.Get("interval") == "5min":
+			rsp = sampleAlphaVantageIntradayResponse
+		case r.URL.Path == "/query" && query.Get("function") == "FX_DAILY" && query.Get("from_symbol") == "EUR" && query.Get("to_symbol") == "USD":
+			rsp = sampleAlphaVantageFxResponse
+		default:
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:                "alphavantage",
+		AlphaVantageQueryUrl:    ts.URL,
+		AlphaVantageSecretToken: "SECRET_TOKEN",
+		AlphaVantageRateLimit:   "100/s",
+		DialTimeout:             time.Second,
+		Bursts:                  1,
+		Tickers:                 []string{"AAPL"},
+		Debug:                   false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from, _ := time.Parse("2006-01-02", "2020-08-01")
+	to, _ := time.Parse("2006-01-02", "2020-09-01")
+	chart, err := n.GetChart(context, "AAPL", "1d", from, to)
+	require.NoError(t, err)
+	require.Equal(t, types.AdjustmentNone, chart.Adjustment, "Adjustment must be the same")
+	require.Equal(t, 2, len(chart.Ohlc), "Should contain two items")
+	require.True(t, chart.Ohlc[0].Timestamp.Before(chart.Ohlc[1].Timestamp), "Bars must be sorted")
+	require.Equal(t, int64(49511403), chart.Ohlc[0].Volume, "Volume must be the same")
+	require.InDelta(t, 444.45, chart.Ohlc[0].Close, 0.01, "Close must be the same")
+	require.InDelta(t, 109.3858, chart.Ohlc[0].AdjClose, 0.0001, "AdjClose must be the same")
+	require.Equal(t, 1, len(chart.Dividends), "Should contain one dividend")
+	require.InDelta(t, 0.82, chart.Dividends[0].Amount, 0.0001, "Amount must be the same")
+	require.Equal(t, 1, len(chart.Splits), "Should contain one split")
+	require.Equal(t, 4.0, chart.Splits[0].Ratio(), "Ratio must be the same")
+
+	loc, _ := time.LoadLocation("US/Eastern")
+	from, _ = time.Parse("2006-01-02", "2021-03-05")
+	chart, err = n.GetChart(context, "AAPL", "5m", from, from.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, types.AdjustmentAll, chart.Adjustment, "Adjustment must be the same")
+	require.Equal(t, 1, len(chart.Ohlc), "Should contain one item")
+	require.True(t, time.Date(2021, 3, 5, 9, 35, 0, 0, loc).Equal(chart.Ohlc[0].Timestamp), "Timestamp must be the same")
+
+	chart, err = n.GetChart(context, "EURUSD=X", "1d", from, from)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(chart.Ohlc), "Should contain one item")
+	require.Equal(t, "EURUSD=X", chart.Ohlc[0].Ticker, "Ticker must be the same")
+	require.Equal(t, int64(0), chart.Ohlc[0].Volume, "Volume must be the same")
+	require.InDelta(t, 1.1916, chart.Ohlc[0].Close, 0.0001, "Close must be the same")
+
+	_, err = n.GetChart(context, "EUR/USD", "5m", from, from)
+	require.Error(t, err, "Intraday currency pairs must fail")
+}
+
+func TestAlphaVantageThrottle(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+		fmt.Fprintln(w, `{"Note": "Thank you for using Alpha Vantage! Our standard API call frequency is 5 calls per minute and 100 calls per day."}`)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:                "alphavantage",
+		AlphaVantageQueryUrl:    ts.URL,
+		AlphaVantageSecretToken: "SECRET_TOKEN",
+		AlphaVantageRateLimit:   "100/s",
+		DialTimeout:             time.Second,
+		Bursts:                  1,
+		Tickers:                 []string{"AAPL"},
+		Debug:                   false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	tm, _ := time.Parse("2006-01-02", "2021-03-05")
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, []string{"AAPL"}, "1d", tm, tm)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+	chart := <-chartChan
+	require.NotNil(t, chart.Err)
+	require.Equal(t, http.StatusTooManyRequests, chart.Err.StatusCode, "Status must be the same")
+	require.Contains(t, chart.Err.Error(), "5 calls per minute")
+}
+
+func TestPolygonChartPagination(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		require.Equal(t, "SECRET_TOKEN", r.URL.Query().Get("apiKey"), "Token must be the same")
+		if strings.HasPrefix(r.URL.Path, "/v2/aggs/ticker/AAPL/range/5/minute/") && r.URL.Query().Get("cursor") == "" {
+			require.Equal(t, "false", r.URL.Query().Get("adjusted"), "Adjusted must be the same")
+			rsp = fmt.Sprintf(`{"ticker": "AAPL", "status": "OK", "resultsCount": 1,
+				"results": [{"v": 1523001, "o": 120.98, "c": 121.1, "h": 121.5, "l": 120.2, "t": 1614954900000}],
+				"next_url": "%s/v2/aggs/ticker/AAPL/range/5/minute/1614955200000/1614988800000?cursor=page2"}`, ts.URL)
+		} else if r.URL.Query().Get("cursor") == "page2" {
+			rsp = `{"ticker": "AAPL", "status": "OK", "resultsCount": 1,
+				"results": [{"v": 989120, "o": 121.1, "c": 121.3, "h": 121.4, "l": 120.9, "t": 1614955200000}]}`
+		} else {
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:           "polygon",
+		PolygonQueryUrl:    ts.URL,
+		PolygonSecretToken: "SECRET_TOKEN",
+		PolygonUnadjusted:  true,
+		PolygonRateLimit:   "100/s",
+		DialTimeout:        time.Second,
+		Bursts:             1,
+		Tickers:            []string{"AAPL"},
+		Debug:              false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from := time.Unix(1614954600, 0)
+	to := time.Unix(1614988800, 0)
+	chart, err := n.GetChart(context, "AAPL", "5m", from, to)
+	require.NoError(t, err)
+	require.Equal(t, types.AdjustmentNone, chart.Adjustment, "Adjustment must be the same")
+	require.Equal(t, 2, len(chart.Ohlc), "Should contain two items")
+	require.True(t, time.Unix(1614954900, 0).Equal(chart.Ohlc[0].Timestamp), "Timestamp must be the same")
+	require.True(t, time.Unix(1614955200, 0).Equal(chart.Ohlc[1].Timestamp), "Timestamp must be the same")
+	require.Equal(t, int64(989120), chart.Ohlc[1].Volume, "Volume must be the same")
+	require.InDelta(t, 121.3, chart.Ohlc[1].Close, 0.01, "Close must be the same")
+
+	_, err = n.GetChart(context, "AAPL", "2m", from, to)
+	require.Error(t, err, "Unsupported intervals must fail")
+}
+
+func TestPolygonGroupedBatch(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp string
+		switch r.URL.Path {
+		case "/v2/aggs/grouped/locale/us/market/stocks/2021-03-04":
+			rsp = `{"status": "OK", "resultsCount": 3, "results": [
+				{"T": "AAPL", "v": 178154975, "o": 121.75, "c": 120.13, "h": 123.6, "l": 118.62, "t": 1614891600000},
+				{"T": "GME", "v": 30733670, "o": 125.5, "c": 132.35, "h": 133.8, "l": 111.5, "t": 1614891600000},
+				{"T": "MSFT", "v": 39025400, "o": 226.74, "c": 226.73, "h": 232.49, "l": 224.26, "t": 1614891600000}]}`
+		case "/v2/aggs/grouped/locale/us/market/stocks/2021-03-05":
+			rsp = `{"status": "OK", "resultsCount": 1, "results": [
+				{"T": "AAPL", "v": 153766601, "o": 120.98, "c": 121.41, "h": 121.94, "l": 117.57, "t": 1614978000000}]}`
+		default:
+			panic("Cannot handle request")
+		}
+		requests++
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:           "polygon",
+		PolygonQueryUrl:    ts.URL,
+		PolygonSecretToken: "SECRET_TOKEN",
+		PolygonRateLimit:   "100/s",
+		DialTimeout:        time.Second,
+		Bursts:             1,
+		Tickers:            []string{"AAPL", "GME", "TSLA"},
+		Debug:              false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	loc, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2021, 3, 4, 0, 0, 0, 0, loc)
+	to := time.Date(2021, 3, 5, 0, 0, 0, 0, loc)
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	n.GetOhlcBatch(context, &wg, chartChan, configuration.Tickers, "1d", from, to)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+	charts := make(map[string]*types.Chart)
+	for chart := range chartChan {
+		require.Nil(t, chart.Err)
+		charts[chart.Ticker] = chart
+	}
+	require.Equal(t, 2, requests, "Should fetch one grouped daily per day")
+	require.Equal(t, 3, len(charts), "Should contain three charts")
+	require.Equal(t, 2, len(charts["AAPL"].Ohlc), "Should contain two items")
+	require.Equal(t, types.AdjustmentSplits, charts["AAPL"].Adjustment, "Adjustment must be the same")
+	require.True(t, from.Equal(charts["AAPL"].Ohlc[0].Timestamp), "Timestamp must be the same")
+	require.True(t, to.Equal(charts["AAPL"].Ohlc[1].Timestamp), "Timestamp must be the same")
+	require.Equal(t, 1, len(charts["GME"].Ohlc), "Should contain one item")
+	require.InDelta(t, 132.35, charts["GME"].Ohlc[0].Close, 0.01, "Close must be the same")
+	require.Empty(t, charts["TSLA"].Ohlc, "Chart must be empty")
+}
+
+func TestPolygonBatchRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		var rsp string
+		if strings.HasPrefix(r.URL.Path, "/v2/aggs/ticker/") && r.URL.Query().Get("cursor") == "" {
+			rsp = fmt.Sprintf(`{"status": "OK", "resultsCount": 1,
+				"results": [{"v": 1523001, "o": 120.98, "c": 121.1, "h": 121.5, "l": 120.2, "t": 1614834000000}],
+				"next_url": "%s%s?cursor=page2"}`, ts.URL, r.URL.Path)
+		} else if r.URL.Query().Get("cursor") == "page2" {
+			rsp = `{"status": "OK", "resultsCount": 1,
+				"results": [{"v": 989120, "o": 121.1, "c": 121.3, "h": 121.4, "l": 120.9, "t": 1614920400000}]}`
+		} else if strings.HasPrefix(r.URL.Path, "/v2/aggs/grouped/") {
+			rsp = `{"status": "OK", "resultsCount": 0, "results": []}`
+		} else {
+			panic("Cannot handle request")
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintln(w, rsp)
+	}))
+	defer ts.Close()
+
+	loc, _ := time.LoadLocation("America/New_York")
+	batch := func(tickers []string, from time.Time, to time.Time) []*types.Chart {
+		configuration := &config.Configuration{
+			Provider:           "polygon",
+			PolygonQueryUrl:    ts.URL,
+			PolygonSecretToken: "SECRET_TOKEN",
+			PolygonRateLimit:   "1/h",
+			DialTimeout:        time.Second,
+			Bursts:             3,
+		}
+		n, err := NewHandler(*configuration)
+		require.NoError(t, err)
+		c, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var wg sync.WaitGroup
+		chartChan := make(chan *types.Chart)
+		n.GetOhlcBatch(c, &wg, chartChan, tickers, "1d", from, to)
+		go func() {
+			wg.Wait()
+			close(chartChan)
+		}()
+		charts := make([]*types.Chart, 0)
+		for chart := range chartChan {
+			charts = append(charts, chart)
+		}
+		return charts
+	}
+
+	// Two tickers of two pages each take four requests
+	from := time.Date(2021, 3, 4, 0, 0, 0, 0, loc)
+	to := time.Date(2021, 3, 5, 0, 0, 0, 0, loc)
+	charts := batch([]string{"AAPL", "GME"}, from, to)
+	require.Equal(t, 3, requests, "Pages must wait for the rate limit")
+	failed := 0
+	for _, chart := range charts {
+		if chart.Err != nil {
+			failed++
+		}
+	}
+	require.Equal(t, 1, failed, "Should fail one ticker")
+
+	// Six tickers of five trading days take five grouped requests
+	requests = 0
+	from = time.Date(2021, 3, 1, 0, 0, 0, 0, loc)
+	to = time.Date(2021, 3, 5, 0, 0, 0, 0, loc)
+	charts = batch([]string{"AAPL", "GME", "MSFT", "TSLA", "AMC", "NIO"}, from, to)
+	require.Equal(t, 3, requests, "Grouped requests must wait for the rate limit")
+	for _, chart := range charts {
+		require.NotNil(t, chart.Err, "Should fail all tickers")
+	}
+}
+
+func TestBinanceChartPagination(t *testing.T) {
+	from := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	to := from.Add(1499 * time.Minute)
+	starts := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/klines" {
+			panic("Cannot handle request")
+		}
+		values := r.URL.Query()
+		require.Equal(t, "BTCUSDT", values.Get("symbol"), "Symbol must be the same")
+		require.Equal(t, "1m", values.Get("interval"), "Interval must be the same")
+		require.Equal(t, strconv.FormatInt(to.UnixMilli(), 10), values.Get("endTime"), "End time must be the same")
+		starts = append(starts, values.Get("startTime"))
+		start, _ := strconv.ParseInt(values.Get("startTime"), 10, 64)
+		// One kline per minute, at most 1000 per response
+		klines := make([]string, 0)
+		for t := (start + 59999) / 60000 * 60000; t <= to.UnixMilli() && len(klines) < 1000; t += 60000 {
+			klines = append(klines, fmt.Sprintf(`[%d, "48527.30", "48530.00", "48520.10", "48525.50", "1.5", %d, "72788.25", 42, "0.7", "33968.11", "0"]`, t, t+59999))
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintf(w, "[%s]\n", strings.Join(klines, ","))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	configuration := &config.Configuration{
+		Provider:        "binance",
+		BinanceQueryUrl: ts.URL,
+		DialTimeout:     time.Second,
+		Bursts:          1,
+		Tickers:         []string{"BTC-USDT"},
+		Debug:           false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	chart, err := n.GetChart(ctx, "BTC-USDT", "1m", from, to)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(starts), "Should fetch two pages")
+	require.Equal(t, strconv.FormatInt(from.Add(999*time.Minute).UnixMilli()+1, 10), starts[1], "Second page must start after the last kline")
+	require.Equal(t, 1500, len(chart.Ohlc), "Should contain all items")
+	require.Equal(t, "BTC-USDT", chart.Ohlc[0].Ticker, "Ticker must be the same")
+	require.True(t, from.Equal(chart.Ohlc[0].Timestamp), "Timestamp must be the same")
+	require.True(t, to.Equal(chart.Ohlc[1499].Timestamp), "Timestamp must be the same")
+	require.Equal(t, int64(2), chart.Ohlc[0].Volume, "Volume must be the same")
+	require.Equal(t, 1.5, chart.Ohlc[0].BaseVolume, "Base volume must be the same")
+	require.InDelta(t, 72788.25, chart.Ohlc[0].QuoteVolume, 0.01, "Quote volume must be the same")
+	require.Equal(t, int64(42), chart.Ohlc[0].Trades, "Trades must be the same")
+	require.InDelta(t, 48525.5, chart.Ohlc[0].Close, 0.01, "Close must be the same")
+
+	// Each page waits for the rate limit
+	configuration.BinanceRateLimit = "1/h"
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	c, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = n.GetChart(c, "BTC-USDT", "1m", from, to)
+	require.Error(t, err, "Second page must wait for the rate limit")
+	require.Equal(t, 3, len(starts), "Should fetch one more page")
+}
+
+func TestBinanceError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+		w.WriteHeader(http.StatusBadRequest)
+
+		fmt.Fprintln(w, `{"code": -1121, "msg": "Invalid symbol."}`)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:        "binance",
+		BinanceQueryUrl: ts.URL,
+		DialTimeout:     time.Second,
+		Bursts:          1,
+		Tickers:         []string{"FOOBAR"},
+		Debug:           false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	_, err = n.GetChart(context, "FOOBAR", "1d", time.Now().AddDate(0, 0, -7), time.Now())
+	var apiErr *binance.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, -1121, apiErr.Code, "Code must be the same")
+	var statusErr *common.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadRequest, statusErr.StatusCode, "Status must be the same")
+}
+
+func TestCoinbaseChartWindows(t *testing.T) {
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(499 * time.Hour)
+	windows := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/BTC-USD/candles" {
+			panic("Cannot handle request")
+		}
+		values := r.URL.Query()
+		require.Equal(t, "3600", values.Get("granularity"), "Granularity must be the same")
+		start, err := time.Parse(time.RFC3339, values.Get("start"))
+		require.NoError(t, err)
+		end, err := time.Parse(time.RFC3339, values.Get("end"))
+		require.NoError(t, err)
+		require.LessOrEqual(t, int(end.Sub(start).Hours())+1, 300, "Window must not exceed 300 candles")
+		windows++
+		// Candles are sorted newest first
+		candles := make([]string, 0)
+		for t := end; !t.Before(start); t = t.Add(-time.Hour) {
+			candles = append(candles, fmt.Sprintf("[%d, 48520.1, 48530.0, 48527.3, 48525.5, 12.5]", t.Unix()))
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintf(w, "[%s]\n", strings.Join(candles, ","))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	configuration := &config.Configuration{
+		Provider:         "coinbase",
+		CoinbaseQueryUrl: ts.URL,
+		DialTimeout:      time.Second,
+		Bursts:           1,
+		Tickers:          []string{"btc/usd"},
+		Debug:            false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	chart, err := n.GetChart(ctx, "btc/usd", "1h", from, to)
+	require.NoError(t, err)
+	require.Equal(t, 2, windows, "Should fetch two windows")
+	require.Equal(t, 500, len(chart.Ohlc), "Should contain all items")
+	for i, point := range chart.Ohlc {
+		require.True(t, from.Add(time.Duration(i)*time.Hour).Equal(point.Timestamp), "Points must be sorted")
+	}
+	require.InDelta(t, 48527.3, chart.Ohlc[0].Open, 0.01, "Open must be the same")
+	require.InDelta(t, 48520.1, chart.Ohlc[0].Low, 0.01, "Low must be the same")
+	require.Equal(t, int64(13), chart.Ohlc[0].Volume, "Volume must be the same")
+	require.Equal(t, 12.5, chart.Ohlc[0].BaseVolume, "Base volume must be the same")
+
+	_, err = n.GetChart(ctx, "btc/usd", "30m", from, to)
+	require.Error(t, err, "Unsupported intervals must fail")
+
+	// Each window waits for the rate limit
+	configuration.CoinbaseRateLimit = "1/h"
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	c, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = n.GetChart(c, "btc/usd", "1h", from, to)
+	require.Error(t, err, "Second window must wait for the rate limit")
+	require.Equal(t, 3, windows, "Should fetch one more window")
+}
+
+func TestKrakenChartPagination(t *testing.T) {
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(999 * time.Hour)
+	latest := from.Add(1100 * time.Hour)
+	sinces := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/OHLC" {
+			panic("Cannot handle request")
+		}
+		values := r.URL.Query()
+		require.Equal(t, "XBTUSD", values.Get("pair"), "Pair must be the same")
+		require.Equal(t, "60", values.Get("interval"), "Interval must be the same")
+		sinces = append(sinces, values.Get("since"))
+		since, _ := strconv.ParseInt(values.Get("since"), 10, 64)
+		// At most 720 candles strictly after 'since'
+		candles := make([]string, 0)
+		var last int64
+		for t := (since/3600 + 1) * 3600; t <= latest.Unix() && len(candles) < 720; t += 3600 {
+			candles = append(candles, fmt.Sprintf(`[%d, "48527.3", "48530.0", "48520.1", "48525.5", "48526.0", "2.5", 17]`, t))
+			last = t
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintf(w, `{"error": [], "result": {"XXBTZUSD": [%s], "last": %d}}`+"\n", strings.Join(candles, ","), last)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	configuration := &config.Configuration{
+		Provider:        "kraken",
+		KrakenQueryUrl:  ts.URL,
+		KrakenRateLimit: "100/s",
+		DialTimeout:     time.Second,
+		Bursts:          1,
+		Tickers:         []string{"XBT/USD"},
+		Debug:           false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	chart, err := n.GetChart(ctx, "XBT/USD", "1h", from, to)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sinces), "Should fetch two pages")
+	require.Equal(t, strconv.FormatInt(from.Add(719*time.Hour).Unix(), 10), sinces[1], "Second page must start after the last candle")
+	require.Equal(t, 1000, len(chart.Ohlc), "Should contain all items")
+	require.True(t, from.Equal(chart.Ohlc[0].Timestamp), "Timestamp must be the same")
+	require.True(t, to.Equal(chart.Ohlc[999].Timestamp), "Timestamp must be the same")
+	require.Equal(t, int64(3), chart.Ohlc[0].Volume, "Volume must be the same")
+	require.Equal(t, 2.5, chart.Ohlc[0].BaseVolume, "Base volume must be the same")
+	require.InDelta(t, 121315.0, chart.Ohlc[0].QuoteVolume, 0.01, "Quote volume must be the same")
+	require.Equal(t, int64(17), chart.Ohlc[0].Trades, "Trades must be the same")
+
+	// Each page waits for the rate limit
+	configuration.KrakenRateLimit = "1/h"
+	n, err = NewHandler(*configuration)
+	require.NoError(t, err)
+	c, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = n.GetChart(c, "XBT/USD", "1h", from, to)
+	require.Error(t, err, "Second page must wait for the rate limit")
+	require.Equal(t, 3, len(sinces), "Should fetch one more page")
+}
+
+func TestKrakenChartTruncated(t *testing.T) {
+	latest := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the latest 720 candles are served whatever 'since' is
+		candles := make([]string, 0)
+		for t := latest.Add(-719 * time.Hour); !t.After(latest); t = t.Add(time.Hour) {
+			candles = append(candles, fmt.Sprintf(`[%d, "48527.3", "48530.0", "48520.1", "48525.5", "48526.0", "2.5", 17]`, t.Unix()))
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintf(w, `{"error": [], "result": {"XXBTZUSD": [%s], "last": %d}}`+"\n", strings.Join(candles, ","), latest.Unix())
+	}))
+	defer ts.Close()
+
+	configuration := &config.Configuration{
+		Provider:        "kraken",
+		KrakenQueryUrl:  ts.URL,
+		KrakenRateLimit: "100/s",
+		DialTimeout:     time.Second,
+		Bursts:          1,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	_, err = n.GetChart(context.Background(), "XBT/USD", "1h", latest.AddDate(0, -2, 0), latest)
+	require.Error(t, err, "Candles older than the latest 720 must fail")
+
+	chart, err := n.GetChart(context.Background(), "XBT/USD", "1h", latest.Add(-719*time.Hour), latest)
+	require.NoError(t, err)
+	require.Equal(t, 720, len(chart.Ohlc), "Should contain all items")
+}
+
+func TestKrakenError(t *testing.T) {
+	message := "EQuery:Unknown asset pair"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = []string{"application/json"}
+
+		fmt.Fprintf(w, `{"error": ["%s"]}`+"\n", message)
+	}))
+	defer ts.Close()
+
+	context := context.Background()
+	configuration := &config.Configuration{
+		Provider:        "kraken",
+		KrakenQueryUrl:  ts.URL,
+		KrakenRateLimit: "100/s",
+		DialTimeout:     time.Second,
+		Bursts:          1,
+		Tickers:         []string{"FOOBAR"},
+		Debug:           false,
+	}
+	n, err := NewHandler(*configuration)
+	require.NoError(t, err)
+
+	from := time.Now().AddDate(0, 0, -7)
+	_, err = n.GetChart(context, "FOOBAR", "1d", from, time.Now())
+	var apiErr *kraken.APIError
+	require.ErrorAs(t, err, &apiErr)
+	var statusErr *common.StatusError
+	require.False(t, errors.As(err, &statusErr), "Unknown pairs must not be reported as a status")
+
+	message = "EAPI:Rate limit exceeded"
+	_, err = n.GetChart(context, "FOOBAR", "1d", from, time.Now())
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode, "Status must be the same")
+}
@@ -17,7 +17,9 @@ package iex
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/regel/wsb/pkg/common"
 	"github.com/regel/wsb/pkg/finance/types"
 	"log"
 	"math"
@@ -36,6 +38,8 @@ type Response struct {
 	Chart []Chart `json:"chart"`
 }
 
+// Prices and volume are adjusted for splits only.
+// The fully adjusted close price is fClose, missing from intraday bars.
 type Chart struct {
 	Date    string  `json:"date"`
 	Minute  string  `json:"minute,omitempty"`
@@ -45,6 +49,7 @@ type Chart struct {
 	High    float64 `json:"high"`
 	Low     float64 `json:"low"`
 	Close   float64 `json:"close"`
+	FClose  float64 `json:"fClose"`
 }
 
 func chunkSlice(slice []string, chunkSize int) [][]string {
@@ -120,6 +125,11 @@ func decodeChart(chart []Chart, ticker string, from time.Time, to time.Time) []t
 				High:      quote.High,
 				Low:       quote.Low,
 				Close:     quote.Close,
+				AdjClose:  quote.FClose,
+			}
+			// Intraday bars have no fClose
+			if point.AdjClose == 0 {
+				point.AdjClose = quote.Close
 			}
 			points = append(points, point)
 		}
@@ -127,23 +137,21 @@ func decodeChart(chart []Chart, ticker string, from time.Time, to time.Time) []t
 	return points
 }
 
-func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) ([]types.Ohlc, error) {
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
 	slice := []string{ticker}
 	queryUrl := getBatchUrl(p.IexCloudQueryUrl, p.IexCloudSecretToken, slice, interval, from, to)
 	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ctx, cancel := context.WithTimeout(c, time.Duration(5*time.Second))
-	defer cancel()
-	req = req.WithContext(ctx)
+	req = req.WithContext(c)
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Non-OK HTTP status: %d", res.StatusCode)
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
 	}
 
 	response := map[string]Response{}
@@ -151,8 +159,12 @@ func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string,
 	if err != nil {
 		return nil, err
 	}
-	chart := response[ticker].Chart
-	return decodeChart(chart, ticker, from, to), nil
+	chart := &types.Chart{
+		Ohlc:       decodeChart(response[ticker].Chart, ticker, from, to),
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentSplits,
+	}
+	return chart, nil
 }
 
 func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
@@ -160,40 +172,58 @@ func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart,
 	for _, chunk := range chunks {
 		wg.Add(1)
 		go func(slice []string, window string, from time.Time, to time.Time) {
+			defer wg.Done()
+			fail := func(tickers []string, err error) {
+				for _, ticker := range tickers {
+					chartChan <- &types.Chart{
+						Ticker: ticker,
+						Err:    types.NewTickerError(ticker, types.ProviderIEX, err),
+					}
+				}
+			}
 			queryUrl := getBatchUrl(p.IexCloudQueryUrl, p.IexCloudSecretToken, slice, interval, from, to)
 			req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
 			if err != nil {
 				log.Fatal(err)
 			}
-			ctx, cancel := context.WithTimeout(c, time.Duration(5*time.Second))
-			defer cancel()
-			req = req.WithContext(ctx)
+			req = req.WithContext(c)
 			res, err := client.Do(req)
 			if err != nil {
-				wg.Done()
+				fail(slice, err)
 				return
 			}
 			defer res.Body.Close()
 			if res.StatusCode != http.StatusOK {
-				println(fmt.Sprintf("Non-OK HTTP status: %d", res.StatusCode))
+				fail(slice, &common.StatusError{StatusCode: res.StatusCode})
+				return
 			}
 
 			response := map[string]Response{}
 			err = json.NewDecoder(res.Body).Decode(&response)
 			if err != nil {
-				wg.Done()
+				fail(slice, err)
 				return
 			}
 			for ticker := range response {
 				chart := response[ticker].Chart
 				points := decodeChart(chart, ticker, from, to)
 				out := &types.Chart{
-					Ohlc:   points,
-					Ticker: ticker,
+					Ohlc:       points,
+					Ticker:     ticker,
+					Adjustment: types.AdjustmentSplits,
 				}
 				chartChan <- out
 			}
-			wg.Done()
+			// Unknown symbols are left out of the response
+			missing := make([]string, 0)
+			for _, ticker := range slice {
+				if _, ok := response[strings.ToUpper(ticker)]; !ok {
+					if _, ok = response[ticker]; !ok {
+						missing = append(missing, ticker)
+					}
+				}
+			}
+			fail(missing, errors.New("Unknown symbol"))
 		}(chunk, interval, from, to)
 	}
 
@@ -0,0 +1,114 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type QuoteResponse struct {
+	Quote *Quote `json:"quote"`
+}
+
+// Same payload as the /stock/{symbol}/quote endpoint
+type Quote struct {
+	Symbol         string  `json:"symbol"`
+	Currency       string  `json:"currency"`
+	LatestPrice    float64 `json:"latestPrice"`
+	LatestUpdate   int64   `json:"latestUpdate"`
+	IexBidPrice    float64 `json:"iexBidPrice"`
+	IexAskPrice    float64 `json:"iexAskPrice"`
+	Open           float64 `json:"open"`
+	PreviousClose  float64 `json:"previousClose"`
+	Change         float64 `json:"change"`
+	ChangePercent  float64 `json:"changePercent"`
+	LatestVolume   int64   `json:"latestVolume"`
+	IsUSMarketOpen bool    `json:"isUSMarketOpen"`
+}
+
+func getQuoteBatchUrl(baseUrl string, token string, tickers []string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse IEX Cloud base url")
+	}
+	values := url.Values{
+		"token":   []string{token},
+		"symbols": []string{strings.Join(tickers, ",")},
+		"types":   []string{"quote"},
+	}
+	relative := &url.URL{
+		Path:     "/v1/stock/market/batch",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+func (p Provider) GetQuotes(c context.Context, client *http.Client, tickers []string) ([]types.Quote, error) {
+	queryUrl := getQuoteBatchUrl(p.IexCloudQueryUrl, p.IexCloudSecretToken, tickers)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := map[string]QuoteResponse{}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make([]types.Quote, 0)
+	for ticker, data := range response {
+		if data.Quote == nil {
+			continue
+		}
+		q := data.Quote
+		state := types.MarketStateClosed
+		if q.IsUSMarketOpen {
+			state = types.MarketStateRegular
+		}
+		quotes = append(quotes, types.Quote{
+			Ticker:        ticker,
+			Timestamp:     time.Unix(0, q.LatestUpdate*int64(time.Millisecond)),
+			Currency:      q.Currency,
+			Price:         q.LatestPrice,
+			Bid:           q.IexBidPrice,
+			Ask:           q.IexAskPrice,
+			Open:          q.Open,
+			PreviousClose: q.PreviousClose,
+			Change:        q.Change,
+			ChangePercent: q.ChangePercent * 100,
+			Volume:        q.LatestVolume,
+			MarketState:   state,
+		})
+	}
+	return quotes, nil
+}
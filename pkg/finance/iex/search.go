@@ -0,0 +1,82 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+type SearchResult struct {
+	Symbol       string `json:"symbol"`
+	SecurityName string `json:"securityName"`
+	SecurityType string `json:"securityType"`
+	Exchange     string `json:"exchange"`
+}
+
+func getSearchUrl(baseUrl string, token string, query string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse IEX Cloud base url")
+	}
+	values := url.Values{
+		"token": []string{token},
+	}
+	relative := &url.URL{
+		Path:     "/v1/search/" + url.PathEscape(query),
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+func (p Provider) SearchSymbols(c context.Context, client *http.Client, query string) ([]types.Symbol, error) {
+	queryUrl := getSearchUrl(p.IexCloudQueryUrl, p.IexCloudSecretToken, query)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	var response []SearchResult
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]types.Symbol, 0)
+	for _, result := range response {
+		symbols = append(symbols, types.Symbol{
+			Symbol:   result.Symbol,
+			Name:     result.SecurityName,
+			Exchange: result.Exchange,
+			Type:     result.SecurityType,
+			Id:       result.Symbol,
+		})
+	}
+	return symbols, nil
+}
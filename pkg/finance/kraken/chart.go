@@ -0,0 +1,263 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of candles of a response. Kraken only serves the
+	// latest 720 candles of each interval.
+	windowLimit = 720
+	// Maximum number of requests of a chart
+	maxWindows = 1000
+)
+
+// Interval in minutes of the chart intervals
+var intervals = map[string]int{
+	"1m":  1,
+	"5m":  5,
+	"15m": 15,
+	"30m": 30,
+	"60m": 60,
+	"1h":  60,
+	"4h":  240,
+	"1d":  1440,
+	"1wk": 10080,
+	"1w":  10080,
+	"15d": 21600,
+}
+
+// APIError is the list of error messages of a response. Errors are sent
+// with a '200 OK' status, e.g. '{"error":["EQuery:Unknown asset pair"]}'.
+type APIError struct {
+	Messages []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Kraken error: %s", strings.Join(e.Messages, ", "))
+}
+
+// Unwrap reports rate limit errors as a '429 Too Many Requests' status
+func (e *APIError) Unwrap() error {
+	for _, message := range e.Messages {
+		if strings.HasPrefix(message, "EAPI:Rate limit") {
+			return &common.StatusError{StatusCode: http.StatusTooManyRequests}
+		}
+	}
+	return nil
+}
+
+type Response struct {
+	Error  []string                   `json:"error"`
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+// Candle is the bar of the window starting at Time, in seconds.
+// Prices and volumes are strings in responses.
+type Candle struct {
+	Time   int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Vwap   float64
+	Volume float64
+	Count  int64
+}
+
+// UnmarshalJSON decodes a candle from its array representation
+func (k *Candle) UnmarshalJSON(b []byte) error {
+	var fields []interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 8 {
+		return fmt.Errorf("Invalid candle %s", b)
+	}
+	t, ok1 := fields[0].(float64)
+	count, ok2 := fields[7].(float64)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("Invalid candle %s", b)
+	}
+	k.Time = int64(t)
+	k.Count = int64(count)
+	values := []*float64{&k.Open, &k.High, &k.Low, &k.Close, &k.Vwap, &k.Volume}
+	for i, v := range values {
+		s, ok := fields[i+1].(string)
+		if !ok {
+			return fmt.Errorf("Invalid candle %s", b)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*v = f
+	}
+	return nil
+}
+
+// Pair returns the Kraken name of a trading pair, e.g. 'XBTUSD' for
+// 'xbtusd', 'XBT-USD' or 'XBT/USD'
+func Pair(ticker string) string {
+	r := strings.NewReplacer("-", "", "/", "")
+	return strings.ToUpper(r.Replace(ticker))
+}
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+func (p Provider) getUrl(ticker string, interval int, since int64) string {
+	base, err := url.Parse(p.KrakenQueryUrl)
+	if err != nil {
+		panic("Can't parse Kraken base url")
+	}
+	values := url.Values{
+		"pair":     []string{Pair(ticker)},
+		"interval": []string{strconv.Itoa(interval)},
+		"since":    []string{strconv.FormatInt(since, 10)},
+	}
+	relative := &url.URL{
+		Path:     "/0/public/OHLC",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// get returns the candles of a response and the 'last' id to use as
+// 'since' in the next request
+func (p Provider) get(c context.Context, client *http.Client, queryUrl string) ([]Candle, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := &Response{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(response.Error) > 0 {
+		return nil, 0, &APIError{Messages: response.Error}
+	}
+	var candles []Candle
+	var last int64
+	// The result is keyed by the canonical pair name, e.g. 'XXBTZUSD'
+	// for 'XBTUSD', next to the 'last' id
+	for key, raw := range response.Result {
+		if key == "last" {
+			err = json.Unmarshal(raw, &last)
+		} else {
+			err = json.Unmarshal(raw, &candles)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return candles, last, nil
+}
+
+// GetChart requests the candles since the start of the time range and
+// follows the 'last' id of responses until the end of the range. Older
+// candles than the latest 720 of the interval are not available: the
+// chart fails rather than starting after the time range.
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	minutes, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported interval '%s'", interval)
+	}
+	points := make([]types.Ohlc, 0)
+	// Candles are returned strictly after 'since'
+	since := from.Unix() - 1
+	for window := 0; since < to.Unix(); window++ {
+		if window == maxWindows {
+			return nil, fmt.Errorf("Too many windows of '%s' candles", ticker)
+		}
+		candles, last, err := p.get(c, client, p.getUrl(ticker, minutes, since))
+		if err != nil {
+			return nil, err
+		}
+		// A full response starting after the first candle of the range
+		// holds the latest candles only
+		if window == 0 && len(candles) >= windowLimit && candles[0].Time > since+int64(minutes*60) {
+			return nil, fmt.Errorf("Kraken only serves the latest %d candles of '%s' interval: '%s' candles start at %s",
+				windowLimit, interval, ticker, time.Unix(candles[0].Time, 0).UTC().Format(time.RFC3339))
+		}
+		for _, candle := range candles {
+			t := time.Unix(candle.Time, 0).UTC()
+			if candle.Time <= since || !timeWithinRange(t, from, to) {
+				continue
+			}
+			points = append(points, types.Ohlc{
+				Ticker:    ticker,
+				Timestamp: t,
+				Open:      candle.Open,
+				High:      candle.High,
+				Low:       candle.Low,
+				Close:     candle.Close,
+				AdjClose:  candle.Close,
+				// Volume of the base asset, e.g. XBT for XBTUSD
+				Volume:     int64(math.Round(candle.Volume)),
+				BaseVolume: candle.Volume,
+				// Kraken has no quote volume. The volume weighted average
+				// price times the base volume is its approximation.
+				QuoteVolume: candle.Vwap * candle.Volume,
+				Trades:      candle.Count,
+			})
+		}
+		if len(candles) < windowLimit || last <= since {
+			break
+		}
+		since = last
+	}
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentNone,
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return false
+}
+
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	// not implemented
+}
@@ -0,0 +1,16 @@
+package kraken
+
+import (
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	KrakenQueryUrl string
+}
+
+func NewProvider(KrakenQueryUrl string) types.Provider {
+	return &Provider{
+		KrakenQueryUrl: KrakenQueryUrl,
+	}
+}
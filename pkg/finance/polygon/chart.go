@@ -0,0 +1,302 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package polygon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of aggregates of a page
+	pageLimit = 50000
+	// Maximum number of pages of a chart
+	maxPages = 1000
+)
+
+// Time zone of the US stock exchanges. Daily bars start at midnight.
+var exchange, _ = time.LoadLocation("America/New_York")
+
+type span struct {
+	multiplier int
+	timespan   string
+}
+
+// Multiplier and timespan of the chart intervals
+var intervals = map[string]span{
+	"1m":  {1, "minute"},
+	"5m":  {5, "minute"},
+	"15m": {15, "minute"},
+	"30m": {30, "minute"},
+	"60m": {1, "hour"},
+	"1h":  {1, "hour"},
+	"1d":  {1, "day"},
+	"1wk": {1, "week"},
+	"1mo": {1, "month"},
+}
+
+type Response struct {
+	Ticker       string      `json:"ticker"`
+	Status       string      `json:"status"`
+	Error        string      `json:"error"`
+	ResultsCount int         `json:"resultsCount"`
+	Results      []Aggregate `json:"results"`
+	NextUrl      string      `json:"next_url"`
+}
+
+// Aggregate is the bar of the window starting at T, in milliseconds
+type Aggregate struct {
+	Ticker string  `json:"T"`
+	Volume float64 `json:"v"`
+	Open   float64 `json:"o"`
+	High   float64 `json:"h"`
+	Low    float64 `json:"l"`
+	Close  float64 `json:"c"`
+	T      int64   `json:"t"`
+}
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+func (p Provider) getUrl(ticker string, interval string, from time.Time, to time.Time) (string, error) {
+	base, err := url.Parse(p.PolygonQueryUrl)
+	if err != nil {
+		panic("Can't parse Polygon base url")
+	}
+	s, ok := intervals[interval]
+	if !ok {
+		return "", fmt.Errorf("Unsupported interval '%s'", interval)
+	}
+	values := url.Values{
+		"adjusted": []string{strconv.FormatBool(p.Adjusted)},
+		"sort":     []string{"asc"},
+		"limit":    []string{strconv.Itoa(pageLimit)},
+		"apiKey":   []string{p.PolygonSecretToken},
+	}
+	relative := &url.URL{
+		Path: fmt.Sprintf("/v2/aggs/ticker/%s/range/%d/%s/%d/%d",
+			url.PathEscape(ticker), s.multiplier, s.timespan, from.UnixMilli(), to.UnixMilli()),
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String(), nil
+}
+
+func (p Provider) getGroupedUrl(date time.Time) string {
+	base, err := url.Parse(p.PolygonQueryUrl)
+	if err != nil {
+		panic("Can't parse Polygon base url")
+	}
+	values := url.Values{
+		"adjusted": []string{strconv.FormatBool(p.Adjusted)},
+		"apiKey":   []string{p.PolygonSecretToken},
+	}
+	relative := &url.URL{
+		Path:     "/v2/aggs/grouped/locale/us/market/stocks/" + date.Format("2006-01-02"),
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// withToken returns the next page url with the API key, which is not
+// included in the 'next_url' of responses
+func (p Provider) withToken(nextUrl string) (string, error) {
+	u, err := url.Parse(nextUrl)
+	if err != nil {
+		return "", err
+	}
+	values := u.Query()
+	values.Set("apiKey", p.PolygonSecretToken)
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+func (p Provider) get(c context.Context, client *http.Client, queryUrl string) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := &Response{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Status == "ERROR" {
+		return nil, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+func (p Provider) adjustment() string {
+	if p.Adjusted {
+		return types.AdjustmentSplits
+	}
+	return types.AdjustmentNone
+}
+
+func newOhlc(ticker string, a Aggregate) types.Ohlc {
+	return types.Ohlc{
+		Ticker:    ticker,
+		Timestamp: time.UnixMilli(a.T).In(exchange),
+		Open:      a.Open,
+		High:      a.High,
+		Low:       a.Low,
+		Close:     a.Close,
+		AdjClose:  a.Close,
+		Volume:    int64(a.Volume),
+	}
+}
+
+// GetChart follows the 'next_url' of responses until all the aggregates of
+// the time range are fetched
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	queryUrl, err := p.getUrl(ticker, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]types.Ohlc, 0)
+	for page := 0; queryUrl != ""; page++ {
+		if page == maxPages {
+			return nil, fmt.Errorf("Too many pages of '%s' aggregates", ticker)
+		}
+		response, err := p.get(c, client, queryUrl)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range response.Results {
+			ohlc := newOhlc(ticker, a)
+			if timeWithinRange(ohlc.Timestamp, from, to) {
+				points = append(points, ohlc)
+			}
+		}
+		queryUrl = ""
+		if response.NextUrl != "" {
+			if queryUrl, err = p.withToken(response.NextUrl); err != nil {
+				return nil, err
+			}
+		}
+	}
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: p.adjustment(),
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return true
+}
+
+// tradingDays returns the weekdays of the time range
+func tradingDays(from time.Time, to time.Time) []time.Time {
+	days := make([]time.Time, 0)
+	y, m, d := from.In(exchange).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, exchange); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// GetOhlcBatch fetches the daily bars of all the tickers with one grouped
+// daily request per trading day if this takes fewer requests than fetching
+// each ticker. Other intervals and long time ranges are fetched ticker by
+// ticker.
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	days := tradingDays(from, to)
+	if interval != "1d" || len(days) >= len(tickers) {
+		for _, ticker := range tickers {
+			wg.Add(1)
+			go func(ticker string) {
+				defer wg.Done()
+				chart, err := p.GetChart(c, client, ticker, interval, from, to)
+				if err != nil {
+					chart = &types.Chart{
+						Ticker: ticker,
+						Err:    types.NewTickerError(ticker, types.ProviderPolygon, err),
+					}
+				}
+				chartChan <- chart
+			}(ticker)
+		}
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		charts := make(map[string]*types.Chart)
+		for _, ticker := range tickers {
+			charts[strings.ToUpper(ticker)] = &types.Chart{
+				Ohlc:       make([]types.Ohlc, 0),
+				Ticker:     ticker,
+				Adjustment: p.adjustment(),
+			}
+		}
+		for _, day := range days {
+			response, err := p.get(c, client, p.getGroupedUrl(day))
+			if err != nil {
+				for _, chart := range charts {
+					chartChan <- &types.Chart{
+						Ticker: chart.Ticker,
+						Err:    types.NewTickerError(chart.Ticker, types.ProviderPolygon, err),
+					}
+				}
+				return
+			}
+			for _, a := range response.Results {
+				chart, ok := charts[strings.ToUpper(a.Ticker)]
+				if !ok {
+					continue
+				}
+				// Grouped bars are stamped at the close of the session.
+				// Daily bars start at midnight like the bars of GetChart.
+				ohlc := newOhlc(chart.Ticker, a)
+				ohlc.Timestamp = day
+				if timeWithinRange(ohlc.Timestamp, from, to) {
+					chart.Ohlc = append(chart.Ohlc, ohlc)
+				}
+			}
+		}
+		// Tickers that did not trade have an empty chart
+		for _, chart := range charts {
+			chartChan <- chart
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+package polygon
+
+import (
+	"strconv"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	PolygonQueryUrl    string
+	PolygonSecretToken string
+	// Prices and volume are adjusted for splits if true
+	Adjusted bool
+}
+
+func NewProvider(PolygonQueryUrl string, PolygonSecretToken string, Adjusted bool) types.Provider {
+	return &Provider{
+		PolygonQueryUrl:    PolygonQueryUrl,
+		PolygonSecretToken: PolygonSecretToken,
+		Adjusted:           Adjusted,
+	}
+}
+
+// Options returns the settings changing the charts of the provider
+func (p *Provider) Options() string {
+	return "adjusted=" + strconv.FormatBool(p.Adjusted)
+}
@@ -0,0 +1,245 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package providertest implements a conformance suite of providers
+// run against the fixtures of an httptest server.
+package providertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Fixture describes the chart fixtures served to a provider
+type Fixture struct {
+	// NewProvider creates the provider calling the fixture server at url
+	NewProvider func(url string) types.Provider
+	// Handler serves the chart fixtures. Fixtures may or may not be
+	// filtered by the time range of requests.
+	Handler http.Handler
+	// Ticker with a chart of at least one bar in the fixtures
+	Ticker string
+	// Interval of the chart
+	Interval string
+	// From and To cover all the bars of the chart
+	From time.Time
+	To   time.Time
+	// Time zone of the timestamps of bars. Not checked if nil.
+	Location *time.Location
+	// Payload of a response without any bar. Not checked if empty.
+	Empty string
+}
+
+// Run checks that the provider conforms to the behaviour expected by the
+// handler:
+// * Bars are sorted by timestamp, and within the inclusive range [from, to]
+// * Bars and charts have the requested ticker
+// * Non-200 responses fail with a common.StatusError
+// * Payloads without bars return an empty chart or an error
+// * Calls are abandoned when the context is cancelled
+// Batches are checked if the provider supports them.
+func Run(t *testing.T, f Fixture) {
+	var status int
+	var block bool
+	var payload string
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		code, wait, body := status, block, payload
+		mu.Unlock()
+		if wait {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		if code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+		if body != "" {
+			fmt.Fprintln(w, body)
+			return
+		}
+		f.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	reset := func(code int, wait bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, block = code, wait
+	}
+	serve := func(body string) {
+		mu.Lock()
+		defer mu.Unlock()
+		payload = body
+	}
+
+	provider := f.NewProvider(ts.URL)
+	client := &http.Client{Timeout: 10 * time.Second}
+	c := context.Background()
+
+	full, err := provider.GetChart(c, client, f.Ticker, f.Interval, f.From, f.To)
+	require.NoError(t, err)
+	require.NotEmpty(t, full.Ohlc, "Fixture must contain bars")
+
+	t.Run("sorted", func(t *testing.T) {
+		for i := 1; i < len(full.Ohlc); i++ {
+			require.True(t, full.Ohlc[i-1].Timestamp.Before(full.Ohlc[i].Timestamp), "Timestamps must be sorted")
+		}
+	})
+
+	t.Run("ticker", func(t *testing.T) {
+		require.Equal(t, f.Ticker, full.Ticker, "Ticker must be the same")
+		for _, ohlc := range full.Ohlc {
+			require.Equal(t, f.Ticker, ohlc.Ticker, "Ticker must be the same")
+		}
+	})
+
+	t.Run("location", func(t *testing.T) {
+		if f.Location == nil {
+			t.Skip("No time zone")
+		}
+		for _, ohlc := range full.Ohlc {
+			require.Equal(t, f.Location.String(), ohlc.Timestamp.Location().String(), "Location must be the same")
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		first := full.Ohlc[0].Timestamp
+		last := full.Ohlc[len(full.Ohlc)-1].Timestamp
+
+		chart, err := provider.GetChart(c, client, f.Ticker, f.Interval, first, last)
+		require.NoError(t, err)
+		require.Equal(t, len(full.Ohlc), len(chart.Ohlc), "Range must be inclusive")
+
+		chart, err = provider.GetChart(c, client, f.Ticker, f.Interval, first.Add(time.Second), last)
+		require.NoError(t, err)
+		require.Equal(t, len(full.Ohlc)-1, len(chart.Ohlc), "Bars before from must be filtered")
+		if len(chart.Ohlc) > 0 {
+			require.True(t, last.Equal(chart.Ohlc[len(chart.Ohlc)-1].Timestamp), "Last bar must be included")
+		}
+
+		chart, err = provider.GetChart(c, client, f.Ticker, f.Interval, first, last.Add(-time.Second))
+		require.NoError(t, err)
+		require.Equal(t, len(full.Ohlc)-1, len(chart.Ohlc), "Bars after to must be filtered")
+		if len(chart.Ohlc) > 0 {
+			require.True(t, first.Equal(chart.Ohlc[0].Timestamp), "First bar must be included")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		first := full.Ohlc[0].Timestamp
+		last := full.Ohlc[len(full.Ohlc)-1].Timestamp
+		ranges := [][]time.Time{
+			{first.AddDate(0, 0, -14), first.Add(-time.Second)},
+			{last.Add(time.Second), last.AddDate(0, 0, 14)},
+		}
+		for _, r := range ranges {
+			chart, err := provider.GetChart(c, client, f.Ticker, f.Interval, r[0], r[1])
+			require.NoError(t, err)
+			require.Equal(t, f.Ticker, chart.Ticker, "Ticker must be the same")
+			require.Empty(t, chart.Ohlc, "Chart must be empty")
+		}
+	})
+
+	t.Run("empty payload", func(t *testing.T) {
+		if f.Empty == "" {
+			t.Skip("No empty payload")
+		}
+		serve(f.Empty)
+		defer serve("")
+		chart, err := provider.GetChart(c, client, f.Ticker, f.Interval, f.From, f.To)
+		if err == nil {
+			require.Empty(t, chart.Ohlc, "Chart must be empty")
+		}
+		if provider.BatchSupported() {
+			charts := getBatch(c, provider, client, f)
+			for _, chart := range charts {
+				require.Empty(t, chart.Ohlc, "Chart must be empty")
+			}
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		for _, code := range []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError} {
+			reset(code, false)
+			_, err := provider.GetChart(c, client, f.Ticker, f.Interval, f.From, f.To)
+			var statusErr *common.StatusError
+			require.True(t, errors.As(err, &statusErr), "Error must be a StatusError")
+			require.Equal(t, code, statusErr.StatusCode, "Status must be the same")
+		}
+		reset(0, false)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		reset(0, true)
+		defer reset(0, false)
+		ctx, cancel := context.WithTimeout(c, 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := provider.GetChart(ctx, client, f.Ticker, f.Interval, f.From, f.To)
+		require.Error(t, err)
+		require.Less(t, time.Since(start), time.Second, "Call must be abandoned")
+	})
+
+	if !provider.BatchSupported() {
+		return
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		charts := getBatch(c, provider, client, f)
+		require.Equal(t, 1, len(charts), "Should contain one chart")
+		require.Nil(t, charts[0].Err)
+		require.Equal(t, f.Ticker, charts[0].Ticker, "Ticker must be the same")
+		require.Equal(t, full.Ohlc, charts[0].Ohlc, "Ohlc must be the same")
+	})
+
+	t.Run("batch status", func(t *testing.T) {
+		reset(http.StatusInternalServerError, false)
+		defer reset(0, false)
+		charts := getBatch(c, provider, client, f)
+		require.Equal(t, 1, len(charts), "Should contain one chart")
+		require.Equal(t, f.Ticker, charts[0].Ticker, "Ticker must be the same")
+		require.NotNil(t, charts[0].Err)
+		require.Equal(t, http.StatusInternalServerError, charts[0].Err.StatusCode, "Status must be the same")
+	})
+}
+
+// getBatch returns the charts sent by a batch of the fixture ticker
+func getBatch(c context.Context, provider types.Provider, client *http.Client, f Fixture) []*types.Chart {
+	var wg sync.WaitGroup
+	chartChan := make(chan *types.Chart)
+	provider.GetOhlcBatch(&wg, chartChan, c, client, []string{f.Ticker}, f.Interval, f.From, f.To)
+	go func() {
+		wg.Wait()
+		close(chartChan)
+	}()
+	charts := make([]*types.Chart, 0)
+	for chart := range chartChan {
+		charts = append(charts, chart)
+	}
+	return charts
+}
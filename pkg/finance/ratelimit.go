@@ -0,0 +1,143 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package finance
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"golang.org/x/time/rate"
+)
+
+// Default rate limits of the free plans of providers
+var defaultRateLimits = map[string]string{
+	// Yahoo Finance usage is capped at 2,000 requests/hour
+	types.ProviderYahoo:     "2000/h",
+	types.ProviderIEX:       "100/s",
+	types.ProviderCoingecko: "50/m",
+	// Stooq does not document its limits and blocks abusive clients
+	types.ProviderStooq: "1/s",
+	// Alpha Vantage free tier
+	types.ProviderAlphaVantage: "5/m",
+	types.ProviderPolygon:      "5/m",
+	// Binance allows 6,000 request weights per minute, 2 per klines call
+	types.ProviderBinance: "1200/m",
+	// Public endpoints of Coinbase Exchange are limited at 10 requests/second
+	types.ProviderCoinbase: "10/s",
+	// Kraken recommends at most one public call per second
+	types.ProviderKraken: "1/s",
+}
+
+var ratePeriods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseRateLimit parses a rate limit of the form '<requests>/<period>'. The
+// period is a unit (s, m, h, d) or a duration, e.g. '500/m' or '10/30s'.
+func ParseRateLimit(limit string) (rate.Limit, error) {
+	parts := strings.Split(strings.TrimSpace(limit), "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("Invalid rate limit '%s'", limit)
+	}
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Invalid rate limit '%s'", limit)
+	}
+	period, ok := ratePeriods[parts[1]]
+	if !ok {
+		period, err = time.ParseDuration(parts[1])
+		if err != nil || period <= 0 {
+			return 0, fmt.Errorf("Invalid rate limit '%s'", limit)
+		}
+	}
+	return rate.Limit(n / period.Seconds()), nil
+}
+
+// bucketState is the state of the token bucket of a provider limiter
+type bucketState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// loadRateLimitState reads the token buckets saved by previous runs
+func loadRateLimitState(path string) (map[string]bucketState, error) {
+	state := make(map[string]bucketState)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("Error reading rate limit state '%s': %v", path, err)
+	}
+	return state, nil
+}
+
+// restoreTokens removes from the full bucket of the limiter the tokens
+// used by previous runs and not refilled since
+func restoreTokens(limiter *rate.Limiter, state bucketState, now time.Time) {
+	burst := limiter.Burst()
+	tokens := state.Tokens + now.Sub(state.Updated).Seconds()*float64(limiter.Limit())
+	used := int(math.Ceil(float64(burst) - tokens))
+	// Reservations are limited to the burst size but can be chained
+	// to keep the debt of a previous run
+	for used > 0 && burst > 0 {
+		n := used
+		if n > burst {
+			n = burst
+		}
+		limiter.ReserveN(now, n)
+		used -= n
+	}
+}
+
+// saveRateLimitState writes the token buckets of the limiters
+func saveRateLimitState(path string, sources map[string]*source, now time.Time) error {
+	state := make(map[string]bucketState)
+	for name, s := range sources {
+		state[name] = bucketState{
+			Tokens:  s.limiter.TokensAt(now),
+			Updated: now,
+		}
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
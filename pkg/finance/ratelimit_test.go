@@ -0,0 +1,67 @@
+package finance
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := map[string]rate.Limit{
+		"100/s":   100,
+		"50/m":    rate.Limit(50.0 / 60),
+		"2000/h":  rate.Limit(2000.0 / 3600),
+		"864/d":   0.01,
+		"10/30s":  rate.Limit(10.0 / 30),
+		" 1/2m ":  rate.Limit(1.0 / 120),
+		"0.5/1ms": 500,
+	}
+	for limit, expected := range tests {
+		got, err := ParseRateLimit(limit)
+		require.NoError(t, err, limit)
+		require.InDelta(t, float64(expected), float64(got), 1e-9, limit)
+	}
+	for _, limit := range []string{"", "100", "100/", "/s", "-1/s", "0/s", "1/w", "1/-1s", "a/s"} {
+		_, err := ParseRateLimit(limit)
+		require.Error(t, err, limit)
+	}
+}
+
+func TestRateLimitState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "ratelimit.json")
+	now := time.Now()
+
+	state, err := loadRateLimitState(path)
+	require.NoError(t, err)
+	require.Empty(t, state, "Missing state must be empty")
+
+	limiter := rate.NewLimiter(rate.Every(time.Minute), 5)
+	limiter.AllowN(now, 3)
+	sources := map[string]*source{
+		types.ProviderYahoo: {name: types.ProviderYahoo, limiter: limiter},
+	}
+	require.NoError(t, saveRateLimitState(path, sources, now))
+
+	state, err = loadRateLimitState(path)
+	require.NoError(t, err)
+	require.InDelta(t, 2.0, state[types.ProviderYahoo].Tokens, 1e-6, "Tokens must be the same")
+
+	// The next run shares the budget left by the previous run
+	next := rate.NewLimiter(rate.Every(time.Minute), 5)
+	restoreTokens(next, state[types.ProviderYahoo], now)
+	require.InDelta(t, 2.0, next.TokensAt(now), 1e-6, "Tokens must be restored")
+
+	// Tokens are refilled between runs
+	later := rate.NewLimiter(rate.Every(time.Minute), 5)
+	restoreTokens(later, state[types.ProviderYahoo], now.Add(2*time.Minute))
+	require.InDelta(t, 4.0, later.TokensAt(now.Add(2*time.Minute)), 1e-6, "Tokens must be refilled")
+
+	// A debt larger than the burst is kept
+	debt := rate.NewLimiter(rate.Every(time.Minute), 5)
+	restoreTokens(debt, bucketState{Tokens: -3, Updated: now}, now)
+	require.InDelta(t, -3.0, debt.TokensAt(now), 1e-6, "Debt must be restored")
+}
@@ -0,0 +1,132 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package finance
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// SplitTicker splits a ticker qualified with its provider, e.g.
+// 'coingecko:bitcoin', in the provider name and the provider symbol.
+// The provider is empty if the ticker is not qualified.
+func SplitTicker(ticker string) (string, string) {
+	j := strings.Index(ticker, ":")
+	if j <= 0 {
+		return "", ticker
+	}
+	provider := ticker[:j]
+	for _, name := range types.Providers {
+		if provider == name {
+			return provider, ticker[j+1:]
+		}
+	}
+	return "", ticker
+}
+
+// routeKey returns the key of the ticker in the routes. Keys are not case
+// sensitive since the configuration lowercases them.
+func routeKey(ticker string) string {
+	return strings.ToLower(ticker)
+}
+
+// route returns the providers of the ticker and the symbol to query
+func (h *Handler) route(ticker string) ([]*source, string) {
+	provider, symbol := SplitTicker(ticker)
+	if provider != "" {
+		return []*source{h.named[provider]}, symbol
+	}
+	if chain, ok := h.routes[routeKey(ticker)]; ok {
+		return chain, ticker
+	}
+	return h.sources, ticker
+}
+
+// qualify sets the ticker qualified with its provider, e.g.
+// 'coinbase:BTC-USD', on the chart fetched for its symbol
+func qualify(chart *types.Chart, ticker string) {
+	chart.Ticker = ticker
+	for j := range chart.Ohlc {
+		chart.Ohlc[j].Ticker = ticker
+	}
+	if chart.Err != nil {
+		chart.Err.Ticker = ticker
+	}
+}
+
+// ProviderOf returns the name of the first provider of the ticker
+func (h *Handler) ProviderOf(ticker string) string {
+	chain, _ := h.route(ticker)
+	return chain[0].name
+}
+
+// routeGroup is a group of symbols served by the same providers
+type routeGroup struct {
+	sources []*source
+	symbols []string
+	// Tickers qualified with their provider by symbol in upper case.
+	// Nil if the tickers of the group are not qualified.
+	qualified map[string]string
+}
+
+// ticker returns the ticker of a symbol returned by the providers
+func (g *routeGroup) ticker(symbol string) (string, bool) {
+	ticker, ok := g.qualified[strings.ToUpper(symbol)]
+	return ticker, ok
+}
+
+// group groups the tickers by providers. Qualified tickers are grouped
+// apart from the tickers routed to the same providers so that their charts
+// keep the qualifier. Groups are sorted by the order of their first ticker.
+func (h *Handler) group(tickers []string) []*routeGroup {
+	groups := make(map[string]*routeGroup)
+	order := make(map[string]int)
+	for j, ticker := range tickers {
+		chain, symbol := h.route(ticker)
+		names := make([]string, 0, len(chain))
+		for _, s := range chain {
+			names = append(names, s.name)
+		}
+		key := strings.Join(names, ",")
+		if symbol != ticker {
+			key += ":"
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &routeGroup{sources: chain}
+			if symbol != ticker {
+				g.qualified = make(map[string]string)
+			}
+			groups[key] = g
+			order[key] = j
+		}
+		g.symbols = append(g.symbols, symbol)
+		if g.qualified != nil {
+			g.qualified[strings.ToUpper(symbol)] = ticker
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return order[keys[i]] < order[keys[j]] })
+	out := make([]*routeGroup, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, groups[key])
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stooq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stooq intervals of the chart intervals
+var intervals = map[string]string{
+	"1d":  "d",
+	"1wk": "w",
+	"1mo": "m",
+	"3mo": "q",
+}
+
+// Stooq market suffixes of Yahoo Finance exchange suffixes
+var suffixes = map[string]string{
+	"l":  "uk",
+	"de": "de",
+	"f":  "de",
+	"t":  "jp",
+	"hk": "hk",
+	"pa": "fr",
+	"as": "nl",
+	"mc": "es",
+	"mi": "it",
+	"sw": "ch",
+	"wa": "pl",
+}
+
+// Stooq markets of the symbols
+var markets = map[string]bool{
+	"us": true,
+	"uk": true,
+	"de": true,
+	"jp": true,
+	"hk": true,
+	"fr": true,
+	"nl": true,
+	"es": true,
+	"it": true,
+	"ch": true,
+	"pl": true,
+}
+
+// Stooq responds with a plain text message to unknown symbols
+const noData = "No data"
+
+func timeWithinRange(t time.Time, from time.Time, to time.Time) bool {
+	return (t.Equal(from) || t.After(from)) && (t.Equal(to) || t.Before(to))
+}
+
+// Symbol returns the Stooq symbol of the ticker. Tickers without a market
+// suffix are US tickers, e.g. 'AAPL' is 'aapl.us', and Yahoo Finance
+// exchange suffixes are mapped to Stooq markets, e.g. 'VOD.L' is 'vod.uk'.
+// Other dots are share classes of US tickers, e.g. 'BRK.B' is 'brk-b.us'.
+// Indices, e.g. '^spx', have no suffix.
+func Symbol(ticker string) string {
+	symbol := strings.ToLower(ticker)
+	if strings.HasPrefix(symbol, "^") {
+		return symbol
+	}
+	i := strings.LastIndex(symbol, ".")
+	if i < 0 {
+		return symbol + ".us"
+	}
+	if markets[symbol[i+1:]] {
+		return symbol
+	}
+	if market, ok := suffixes[symbol[i+1:]]; ok {
+		return symbol[:i+1] + market
+	}
+	return strings.ReplaceAll(symbol, ".", "-") + ".us"
+}
+
+func getUrl(baseUrl string, ticker string, interval string, from time.Time, to time.Time) (string, error) {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Stooq base url")
+	}
+	i, ok := intervals[interval]
+	if !ok {
+		return "", fmt.Errorf("Unsupported interval '%s'", interval)
+	}
+	values := url.Values{
+		"s":  []string{Symbol(ticker)},
+		"d1": []string{from.UTC().Format("20060102")},
+		"d2": []string{to.UTC().Format("20060102")},
+		"i":  []string{i},
+	}
+	relative := &url.URL{
+		Path:     "/q/d/l/",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String(), nil
+}
+
+// decodeChart parses the 'Date,Open,High,Low,Close,Volume' rows of a
+// Stooq CSV file. Volume is missing for currencies and indices.
+func decodeChart(r io.Reader, ticker string, from time.Time, to time.Time) ([]types.Ohlc, error) {
+	reader := bufio.NewReader(r)
+	head, err := reader.Peek(len(noData))
+	if err == nil && bytes.Equal(head, []byte(noData)) {
+		return make([]types.Ohlc, 0), nil
+	}
+	records := csv.NewReader(reader)
+	records.FieldsPerRecord = -1
+	header, err := records.Read()
+	if err == io.EOF {
+		return make([]types.Ohlc, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 5 || header[0] != "Date" {
+		// Errors, e.g. exceeded limits, are plain text messages
+		return nil, fmt.Errorf("Unexpected response '%s'", strings.Join(header, ","))
+	}
+	points := make([]types.Ohlc, 0)
+	for {
+		row, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 5 {
+			return nil, errors.New("Invalid row: missing columns")
+		}
+		timestamp, err := time.Parse("2006-01-02", row[0])
+		if err != nil {
+			return nil, err
+		}
+		if !timeWithinRange(timestamp, from, to) {
+			continue
+		}
+		values := make([]float64, 4)
+		for j := range values {
+			if values[j], err = strconv.ParseFloat(row[j+1], 64); err != nil {
+				return nil, err
+			}
+		}
+		var volume int64
+		if len(row) > 5 && row[5] != "" {
+			v, err := strconv.ParseFloat(row[5], 64)
+			if err != nil {
+				return nil, err
+			}
+			volume = int64(v)
+		}
+		points = append(points, types.Ohlc{
+			Ticker:    ticker,
+			Timestamp: timestamp,
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			AdjClose:  values[3],
+			Volume:    volume,
+		})
+	}
+	return points, nil
+}
+
+// Prices and volume are adjusted for splits and dividends
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
+	queryUrl, err := getUrl(p.StooqQueryUrl, ticker, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	points, err := decodeChart(res.Body, ticker, from, to)
+	if err != nil {
+		return nil, err
+	}
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Adjustment: types.AdjustmentAll,
+	}
+	return chart, nil
+}
+
+func (p Provider) BatchSupported() bool {
+	return false
+}
+
+func (p Provider) GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *types.Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time) {
+	// not implemented
+}
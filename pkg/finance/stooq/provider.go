@@ -0,0 +1,16 @@
+package stooq
+
+import (
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// Local type implements the types.Provider interface
+type Provider struct {
+	StooqQueryUrl string
+}
+
+func NewProvider(StooqQueryUrl string) types.Provider {
+	return &Provider{
+		StooqQueryUrl: StooqQueryUrl,
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TickerError is the error of fetching the data of one ticker
+type TickerError struct {
+	Ticker   string
+	Provider string
+	// HTTP status of the provider response, zero if no response was received
+	StatusCode int
+	Err        error
+}
+
+// NewTickerError wraps the error returned by a provider for the ticker
+func NewTickerError(ticker string, provider string, err error) *TickerError {
+	e := &TickerError{
+		Ticker:   ticker,
+		Provider: provider,
+		Err:      err,
+	}
+	var statusErr interface{ Status() int }
+	if errors.As(err, &statusErr) {
+		e.StatusCode = statusErr.Status()
+	}
+	return e
+}
+
+func (e *TickerError) Error() string {
+	return fmt.Sprintf("Error fetching '%s' data: %v", e.Ticker, e.Err)
+}
+
+func (e *TickerError) Unwrap() error {
+	return e.Err
+}
@@ -8,14 +8,35 @@ import (
 )
 
 const (
-	ProviderYahoo     string = "yahoo"
-	ProviderIEX       string = "iex"
-	ProviderCoingecko string = "coingecko"
+	ProviderYahoo        string = "yahoo"
+	ProviderIEX          string = "iex"
+	ProviderCoingecko    string = "coingecko"
+	ProviderStooq        string = "stooq"
+	ProviderAlphaVantage string = "alphavantage"
+	ProviderPolygon      string = "polygon"
+	ProviderBinance      string = "binance"
+	ProviderCoinbase     string = "coinbase"
+	ProviderKraken       string = "kraken"
 )
 
+// Providers lists the names of the supported providers
+var Providers = []string{
+	ProviderYahoo,
+	ProviderIEX,
+	ProviderCoingecko,
+	ProviderStooq,
+	ProviderAlphaVantage,
+	ProviderPolygon,
+	ProviderBinance,
+	ProviderCoinbase,
+	ProviderKraken,
+}
+
 type Provider interface {
 	BatchSupported() bool
-	GetOhlc(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) ([]Ohlc, error)
+	GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*Chart, error)
 	GetOhlcBatch(wg *sync.WaitGroup, chartChan chan *Chart, c context.Context, client *http.Client, tickers []string, interval string, from time.Time, to time.Time)
 	GetHolders(c context.Context, client *http.Client, ticker string) (*HoldersBreakdown, *HoldersTable, *HoldersTable, error)
+	GetQuotes(c context.Context, client *http.Client, tickers []string) ([]Quote, error)
+	SearchSymbols(c context.Context, client *http.Client, query string) ([]Symbol, error)
 }
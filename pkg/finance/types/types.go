@@ -11,12 +11,60 @@ type Ohlc struct {
 	High      float64
 	Low       float64
 	Close     float64
-	Volume    int64
+	// Close price adjusted for splits and dividends
+	AdjClose float64
+	Volume   int64
+	// Volume in the base asset of crypto pairs with its fractional part,
+	// e.g. BTC for BTCUSDT. Volume is this volume rounded. Zero if the
+	// provider reports integer volumes.
+	BaseVolume float64
+	// Volume in the quote asset of crypto pairs, e.g. USDT for BTCUSDT.
+	// Zero if the provider does not report it.
+	QuoteVolume float64
+	// Number of trades, zero if the provider does not report it
+	Trades int64
+}
+
+const (
+	AdjustmentNone   string = "none"
+	AdjustmentSplits string = "splits"
+	AdjustmentAll    string = "all"
+)
+
+// Cash dividend paid on the ex-dividend date. The Amount is expressed
+// in the same share basis as the prices of the chart.
+type Dividend struct {
+	Timestamp time.Time
+	Amount    float64
+}
+
+// Stock split effective on the given date. A 4-for-1 split
+// has a Numerator of 4 and a Denominator of 1.
+type Split struct {
+	Timestamp   time.Time
+	Numerator   float64
+	Denominator float64
+}
+
+// Ratio returns the number of shares held after the split for each share held before
+func (s Split) Ratio() float64 {
+	if s.Denominator == 0 {
+		return 1
+	}
+	return s.Numerator / s.Denominator
 }
 
 type Chart struct {
-	Ohlc   []Ohlc
-	Ticker string
+	Ohlc      []Ohlc
+	Ticker    string
+	Dividends []Dividend
+	Splits    []Split
+	// Adjustment already applied to Ohlc prices and volumes by the provider
+	Adjustment string
+	// Name of the provider that served the chart
+	Provider string
+	// Error fetching the chart of the ticker. The chart has no data if set.
+	Err *TickerError
 }
 
 type HoldersBreakdown struct {
@@ -41,3 +89,38 @@ type HoldersTable struct {
 	Ticker string
 	Rows   []HoldersRow
 }
+
+const (
+	MarketStatePre     string = "PRE"
+	MarketStateRegular string = "REGULAR"
+	MarketStatePost    string = "POST"
+	MarketStateClosed  string = "CLOSED"
+)
+
+// Latest snapshot quote of a ticker
+type Quote struct {
+	Ticker        string
+	Timestamp     time.Time
+	Currency      string
+	Price         float64
+	Bid           float64
+	Ask           float64
+	Open          float64
+	PreviousClose float64
+	// Change since the previous close
+	Change float64
+	// Change since the previous close in %
+	ChangePercent float64
+	Volume        int64
+	MarketState   string
+}
+
+// Result of a symbol search. Id is the provider-specific identifier
+// to use as a ticker name, e.g. 'avalanche-2' for CoinGecko.
+type Symbol struct {
+	Symbol   string
+	Name     string
+	Exchange string
+	Type     string
+	Id       string
+}
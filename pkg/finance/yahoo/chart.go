@@ -18,10 +18,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/regel/wsb/pkg/common"
 	"github.com/regel/wsb/pkg/finance/types"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -44,10 +46,34 @@ type Result struct {
 	Meta       Meta      `json:"meta"`
 	Timestamps []int64   `json:"timestamp"`
 	Indicators Indicator `json:"indicators"`
+	Events     Events    `json:"events"`
 }
 
 type Indicator struct {
-	Quote []Quote `json:"quote"`
+	Quote    []Quote    `json:"quote"`
+	AdjClose []AdjClose `json:"adjclose"`
+}
+
+type AdjClose struct {
+	AdjClose []float64 `json:"adjclose"`
+}
+
+// Events are keyed by their Unix timestamp
+type Events struct {
+	Dividends map[string]Dividend `json:"dividends"`
+	Splits    map[string]Split    `json:"splits"`
+}
+
+type Dividend struct {
+	Amount float64 `json:"amount"`
+	Date   int64   `json:"date"`
+}
+
+type Split struct {
+	Date        int64   `json:"date"`
+	Numerator   float64 `json:"numerator"`
+	Denominator float64 `json:"denominator"`
+	SplitRatio  string  `json:"splitRatio"`
 }
 
 type Quote struct {
@@ -74,6 +100,7 @@ func getUrl(baseUrl string, ticker string, interval string, from time.Time, to t
 		"interval":   []string{interval},
 		"period1":    []string{strconv.FormatInt(from.Unix(), 10)},
 		"period2":    []string{strconv.FormatInt(to.Unix(), 10)},
+		"events":     []string{"div,split"},
 		"region":     []string{"US"},
 		"corsDomain": []string{"com.finance.yahoo"},
 	}
@@ -85,22 +112,51 @@ func getUrl(baseUrl string, ticker string, interval string, from time.Time, to t
 	return base.ResolveReference(relative).String()
 }
 
-func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) ([]types.Ohlc, error) {
+func decodeEvents(events Events, loc *time.Location, from time.Time, to time.Time) ([]types.Dividend, []types.Split) {
+	dividends := make([]types.Dividend, 0)
+	for _, dividend := range events.Dividends {
+		t := time.Unix(dividend.Date, 0).In(loc)
+		if timeWithinRange(t, from, to) {
+			dividends = append(dividends, types.Dividend{
+				Timestamp: t,
+				Amount:    dividend.Amount,
+			})
+		}
+	}
+	sort.Slice(dividends, func(i, j int) bool {
+		return dividends[i].Timestamp.Before(dividends[j].Timestamp)
+	})
+	splits := make([]types.Split, 0)
+	for _, split := range events.Splits {
+		t := time.Unix(split.Date, 0).In(loc)
+		if timeWithinRange(t, from, to) {
+			splits = append(splits, types.Split{
+				Timestamp:   t,
+				Numerator:   split.Numerator,
+				Denominator: split.Denominator,
+			})
+		}
+	}
+	sort.Slice(splits, func(i, j int) bool {
+		return splits[i].Timestamp.Before(splits[j].Timestamp)
+	})
+	return dividends, splits
+}
+
+func (p Provider) GetChart(c context.Context, client *http.Client, ticker string, interval string, from time.Time, to time.Time) (*types.Chart, error) {
 	queryUrl := getUrl(p.YahooFinanceQueryUrl, ticker, interval, from, to)
 	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-	ctx, cancel := context.WithTimeout(c, time.Duration(5*time.Second))
-	defer cancel()
-	req = req.WithContext(ctx)
+	req = req.WithContext(c)
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Non-OK HTTP status: %d", res.StatusCode)
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
 	}
 
 	response := &Response{}
@@ -109,12 +165,28 @@ func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string,
 		return nil, err
 	}
 	points := make([]types.Ohlc, 0)
-	quote := response.Chart.Result[0].Indicators.Quote[0]
-	loc, err := time.LoadLocation(response.Chart.Result[0].Meta.ExchangeTimezoneName)
+	// Tickers without bars may have no result or no quote
+	if len(response.Chart.Result) == 0 || len(response.Chart.Result[0].Indicators.Quote) == 0 {
+		if len(response.Chart.Result) > 0 && len(response.Chart.Result[0].Timestamps) > 0 {
+			return nil, fmt.Errorf("Missing '%s' quotes", ticker)
+		}
+		return &types.Chart{
+			Ohlc:       points,
+			Ticker:     ticker,
+			Adjustment: types.AdjustmentSplits,
+		}, nil
+	}
+	result := response.Chart.Result[0]
+	quote := result.Indicators.Quote[0]
+	var adjClose []float64
+	if len(result.Indicators.AdjClose) > 0 {
+		adjClose = result.Indicators.AdjClose[0].AdjClose
+	}
+	loc, err := time.LoadLocation(result.Meta.ExchangeTimezoneName)
 	if err != nil {
 		return nil, err
 	}
-	for j, timestamp := range response.Chart.Result[0].Timestamps {
+	for j, timestamp := range result.Timestamps {
 		t := time.Unix(timestamp, 0).In(loc)
 		if timeWithinRange(t, from, to) {
 			ohlc := types.Ohlc{
@@ -125,11 +197,23 @@ func (p Provider) GetOhlc(c context.Context, client *http.Client, ticker string,
 				High:      quote.High[j],
 				Low:       quote.Low[j],
 				Close:     quote.Close[j],
+				AdjClose:  quote.Close[j],
+			}
+			if j < len(adjClose) {
+				ohlc.AdjClose = adjClose[j]
 			}
 			points = append(points, ohlc)
 		}
 	}
-	return points, err
+	dividends, splits := decodeEvents(result.Events, loc, from, to)
+	chart := &types.Chart{
+		Ohlc:       points,
+		Ticker:     ticker,
+		Dividends:  dividends,
+		Splits:     splits,
+		Adjustment: types.AdjustmentSplits,
+	}
+	return chart, nil
 }
 
 func (p Provider) BatchSupported() bool {
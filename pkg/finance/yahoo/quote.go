@@ -0,0 +1,120 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yahoo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type QuoteResponse struct {
+	QuoteResponse QuoteResult `json:"quoteResponse"`
+}
+
+type QuoteResult struct {
+	Result []QuoteData `json:"result"`
+}
+
+type QuoteData struct {
+	Symbol                     string  `json:"symbol"`
+	Currency                   string  `json:"currency"`
+	MarketState                string  `json:"marketState"`
+	RegularMarketTime          int64   `json:"regularMarketTime"`
+	RegularMarketPrice         float64 `json:"regularMarketPrice"`
+	RegularMarketOpen          float64 `json:"regularMarketOpen"`
+	RegularMarketPreviousClose float64 `json:"regularMarketPreviousClose"`
+	RegularMarketChange        float64 `json:"regularMarketChange"`
+	RegularMarketChangePercent float64 `json:"regularMarketChangePercent"`
+	RegularMarketVolume        int64   `json:"regularMarketVolume"`
+	Bid                        float64 `json:"bid"`
+	Ask                        float64 `json:"ask"`
+}
+
+func getQuoteUrl(baseUrl string, tickers []string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Yahoo Finance base url")
+	}
+	values := url.Values{
+		"symbols": []string{strings.Join(tickers, ",")},
+	}
+	relative := &url.URL{
+		Path:     "/v7/finance/quote",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+// Yahoo reports extended hours states such as PREPRE or POSTPOST
+func marketState(state string) string {
+	switch {
+	case strings.HasPrefix(state, "PRE"):
+		return types.MarketStatePre
+	case strings.HasPrefix(state, "POST"):
+		return types.MarketStatePost
+	case state == "REGULAR":
+		return types.MarketStateRegular
+	}
+	return types.MarketStateClosed
+}
+
+func (p Provider) GetQuotes(c context.Context, client *http.Client, tickers []string) ([]types.Quote, error) {
+	queryUrl := getQuoteUrl(p.YahooFinanceQueryUrl, tickers)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := &QuoteResponse{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make([]types.Quote, 0)
+	for _, data := range response.QuoteResponse.Result {
+		quotes = append(quotes, types.Quote{
+			Ticker:        data.Symbol,
+			Timestamp:     time.Unix(data.RegularMarketTime, 0),
+			Currency:      data.Currency,
+			Price:         data.RegularMarketPrice,
+			Bid:           data.Bid,
+			Ask:           data.Ask,
+			Open:          data.RegularMarketOpen,
+			PreviousClose: data.RegularMarketPreviousClose,
+			Change:        data.RegularMarketChange,
+			ChangePercent: data.RegularMarketChangePercent,
+			Volume:        data.RegularMarketVolume,
+			MarketState:   marketState(data.MarketState),
+		})
+	}
+	return quotes, nil
+}
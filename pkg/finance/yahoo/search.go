@@ -0,0 +1,97 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yahoo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+const (
+	searchMaxResults = "20"
+)
+
+type SearchResponse struct {
+	Quotes []SearchQuote `json:"quotes"`
+}
+
+type SearchQuote struct {
+	Symbol    string `json:"symbol"`
+	ShortName string `json:"shortname"`
+	LongName  string `json:"longname"`
+	Exchange  string `json:"exchange"`
+	QuoteType string `json:"quoteType"`
+}
+
+func getSearchUrl(baseUrl string, query string) string {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		panic("Can't parse Yahoo Finance base url")
+	}
+	values := url.Values{
+		"q":           []string{query},
+		"quotesCount": []string{searchMaxResults},
+		"newsCount":   []string{"0"},
+	}
+	relative := &url.URL{
+		Path:     "/v1/finance/search",
+		RawQuery: values.Encode(),
+	}
+
+	return base.ResolveReference(relative).String()
+}
+
+func (p Provider) SearchSymbols(c context.Context, client *http.Client, query string) ([]types.Symbol, error) {
+	queryUrl := getSearchUrl(p.YahooFinanceQueryUrl, query)
+	req, err := http.NewRequest(http.MethodGet, queryUrl, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req = req.WithContext(c)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, &common.StatusError{StatusCode: res.StatusCode}
+	}
+
+	response := &SearchResponse{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]types.Symbol, 0)
+	for _, quote := range response.Quotes {
+		name := quote.LongName
+		if name == "" {
+			name = quote.ShortName
+		}
+		symbols = append(symbols, types.Symbol{
+			Symbol:   quote.Symbol,
+			Name:     name,
+			Exchange: quote.Exchange,
+			Type:     quote.QuoteType,
+			Id:       quote.Symbol,
+		})
+	}
+	return symbols, nil
+}
@@ -0,0 +1,334 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+const (
+	FormatTable  string = "table"
+	FormatCSV    string = "csv"
+	FormatJSON   string = "json"
+	FormatNDJSON string = "ndjson"
+)
+
+const (
+	dateFormat     = "2006-01-02"
+	dateFormatLong = "2006-01-02T15:04:05"
+)
+
+// ChartWriter renders the charts received from a provider.
+// Write is called once per ticker and Flush once all charts were written.
+type ChartWriter interface {
+	Write(chart *types.Chart) error
+	Flush() error
+}
+
+// OhlcRecord is the machine-readable representation of a types.Ohlc point
+type OhlcRecord struct {
+	Ticker    string  `json:"ticker"`
+	Timestamp string  `json:"timestamp"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	AdjClose  float64 `json:"adj_close"`
+	Volume    int64   `json:"volume"`
+	// Base volume, quote volume and number of trades of crypto pairs
+	BaseVolume  float64 `json:"base_volume,omitempty"`
+	QuoteVolume float64 `json:"quote_volume,omitempty"`
+	Trades      int64   `json:"trades,omitempty"`
+}
+
+// DividendRecord is the machine-readable representation of a types.Dividend
+type DividendRecord struct {
+	Timestamp string  `json:"timestamp"`
+	Amount    float64 `json:"amount"`
+}
+
+// SplitRecord is the machine-readable representation of a types.Split
+type SplitRecord struct {
+	Timestamp   string  `json:"timestamp"`
+	Numerator   float64 `json:"numerator"`
+	Denominator float64 `json:"denominator"`
+}
+
+// ChartRecord is the machine-readable representation of a types.Chart
+type ChartRecord struct {
+	Ticker     string           `json:"ticker"`
+	Adjustment string           `json:"adjustment,omitempty"`
+	Provider   string           `json:"provider,omitempty"`
+	Ohlc       []OhlcRecord     `json:"ohlc"`
+	Dividends  []DividendRecord `json:"dividends,omitempty"`
+	Splits     []SplitRecord    `json:"splits,omitempty"`
+}
+
+// Columns of CSV files
+var ohlcHeader = []string{
+	"ticker",
+	"timestamp",
+	"open",
+	"high",
+	"low",
+	"close",
+	"volume",
+	"adj_close",
+	"base_volume",
+	"quote_volume",
+	"trades",
+}
+
+// NewChartWriter returns the ChartWriter for the given output format
+func NewChartWriter(format string, w io.Writer) (ChartWriter, error) {
+	switch format {
+	case FormatTable:
+		return &tableChartWriter{w: w}, nil
+	case FormatCSV:
+		return &csvChartWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonChartWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonChartWriter{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return nil, fmt.Errorf("Output format '%s' requires an output directory", format)
+	default:
+		return nil, fmt.Errorf("Unknown output format '%s'", format)
+	}
+}
+
+func formatDate(t time.Time) string {
+	h := t.Hour()
+	m := t.Minute()
+	s := t.Second()
+	n := t.Nanosecond()
+	if h == 0 && m == 0 && s == 0 && n == 0 {
+		return t.In(time.UTC).Format(dateFormat)
+	}
+	return t.In(time.UTC).Format(dateFormatLong)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// NewOhlcRecord converts a point to its machine-readable representation
+func NewOhlcRecord(ticker string, row types.Ohlc) OhlcRecord {
+	return OhlcRecord{
+		Ticker:      ticker,
+		Timestamp:   row.Timestamp.Format(time.RFC3339),
+		Open:        row.Open,
+		High:        row.High,
+		Low:         row.Low,
+		Close:       row.Close,
+		AdjClose:    row.AdjClose,
+		Volume:      row.Volume,
+		BaseVolume:  row.BaseVolume,
+		QuoteVolume: row.QuoteVolume,
+		Trades:      row.Trades,
+	}
+}
+
+// NewChartRecord converts a chart to its machine-readable representation
+func NewChartRecord(chart *types.Chart) ChartRecord {
+	records := make([]OhlcRecord, 0, len(chart.Ohlc))
+	for _, row := range chart.Ohlc {
+		records = append(records, NewOhlcRecord(chart.Ticker, row))
+	}
+	out := ChartRecord{
+		Ticker:     chart.Ticker,
+		Adjustment: chart.Adjustment,
+		Provider:   chart.Provider,
+		Ohlc:       records,
+	}
+	for _, dividend := range chart.Dividends {
+		out.Dividends = append(out.Dividends, DividendRecord{
+			Timestamp: dividend.Timestamp.Format(time.RFC3339),
+			Amount:    dividend.Amount,
+		})
+	}
+	for _, split := range chart.Splits {
+		out.Splits = append(out.Splits, SplitRecord{
+			Timestamp:   split.Timestamp.Format(time.RFC3339),
+			Numerator:   split.Numerator,
+			Denominator: split.Denominator,
+		})
+	}
+	return out
+}
+
+func (r OhlcRecord) strings() []string {
+	return []string{
+		r.Ticker,
+		r.Timestamp,
+		formatFloat(r.Open),
+		formatFloat(r.High),
+		formatFloat(r.Low),
+		formatFloat(r.Close),
+		strconv.FormatInt(r.Volume, 10),
+		formatFloat(r.AdjClose),
+		formatFloat(r.BaseVolume),
+		formatFloat(r.QuoteVolume),
+		strconv.FormatInt(r.Trades, 10),
+	}
+}
+
+// isCrypto returns true if the provider reports the volumes and trades of
+// crypto pairs in the points of the chart
+func isCrypto(chart *types.Chart) bool {
+	for _, row := range chart.Ohlc {
+		if row.BaseVolume != 0 || row.QuoteVolume != 0 || row.Trades != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type tableChartWriter struct {
+	w io.Writer
+}
+
+func (t *tableChartWriter) Write(chart *types.Chart) error {
+	history := tablewriter.NewWriter(t.w)
+	header := []string{
+		"Date",
+		"Open",
+		"High",
+		"Low",
+		"Close",
+		"Adj Close",
+		"Volume",
+	}
+	// Crypto pairs have fractional volumes, a quote volume and trades
+	crypto := isCrypto(chart)
+	if crypto {
+		header = append(header, "Quote Volume", "Trades")
+	}
+	history.SetHeader(header)
+	for _, row := range chart.Ohlc {
+		line := []string{
+			formatDate(row.Timestamp),
+			fmt.Sprintf("%.02f", row.Open),
+			fmt.Sprintf("%.02f", row.High),
+			fmt.Sprintf("%.02f", row.Low),
+			fmt.Sprintf("%.02f", row.Close),
+			fmt.Sprintf("%.02f", row.AdjClose),
+			fmt.Sprintf("%d", row.Volume),
+		}
+		if crypto {
+			if row.BaseVolume != 0 {
+				line[6] = formatFloat(row.BaseVolume)
+			}
+			line = append(line, fmt.Sprintf("%.02f", row.QuoteVolume), fmt.Sprintf("%d", row.Trades))
+		}
+		history.Append(line)
+	}
+	history.SetCaption(true, fmt.Sprintf("History of '%s'.", chart.Ticker))
+	history.Render() // Send output
+	return nil
+}
+
+func (t *tableChartWriter) Flush() error {
+	return nil
+}
+
+type csvChartWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvChartWriter) Write(chart *types.Chart) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(ohlcHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	for _, row := range chart.Ohlc {
+		if err := c.w.Write(NewOhlcRecord(chart.Ticker, row).strings()); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvChartWriter) Flush() error {
+	if !c.wroteHeader {
+		if err := c.w.Write(ohlcHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonChartWriter streams a JSON array with one element per chart
+type jsonChartWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonChartWriter) Write(chart *types.Chart) error {
+	delim := ","
+	if j.count == 0 {
+		delim = "["
+	}
+	b, err := json.Marshal(NewChartRecord(chart))
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(j.w, "%s\n%s", delim, b); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+func (j *jsonChartWriter) Flush() error {
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+// ndjsonChartWriter writes one JSON object per line and per point
+type ndjsonChartWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonChartWriter) Write(chart *types.Chart) error {
+	for _, row := range chart.Ohlc {
+		if err := n.enc.Encode(NewOhlcRecord(chart.Ticker, row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonChartWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleChart() *types.Chart {
+	loc, _ := time.LoadLocation("America/New_York")
+	return &types.Chart{
+		Ticker: "AAPL",
+		Ohlc: []types.Ohlc{
+			{
+				Ticker:    "AAPL",
+				Timestamp: time.Unix(1617307203, 0).In(loc),
+				Open:      123.66000366210938,
+				High:      124.18000030517578,
+				Low:       122.48999786376953,
+				Close:     123.0,
+				AdjClose:  122.75,
+				Volume:    75089134,
+			},
+		},
+	}
+}
+
+func TestUnknownChartFormat(t *testing.T) {
+	_, err := NewChartWriter("xml", &bytes.Buffer{})
+	require.Error(t, err)
+}
+
+func TestCsvChartWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatCSV, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleChart()))
+	require.NoError(t, w.Flush())
+
+	expected := "ticker,timestamp,open,high,low,close,volume,adj_close,base_volume,quote_volume,trades\n" +
+		"AAPL,2021-04-01T16:00:03-04:00,123.66000366210938,124.18000030517578,122.48999786376953,123,75089134,122.75,0,0,0\n"
+	require.Equal(t, expected, b.String())
+}
+
+func TestTableChartWriterCrypto(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatTable, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleChart()))
+	require.NotContains(t, b.String(), "QUOTE VOLUME", "Stock tables must not have a quote volume")
+
+	b.Reset()
+	chart := sampleChart()
+	chart.Ohlc[0].Volume = 2
+	chart.Ohlc[0].BaseVolume = 1.5
+	chart.Ohlc[0].QuoteVolume = 72788.25
+	chart.Ohlc[0].Trades = 42
+	require.NoError(t, w.Write(chart))
+	require.Contains(t, b.String(), "QUOTE VOLUME")
+	require.Contains(t, b.String(), " 1.5 ", "Volume must keep its fractional part")
+	require.Contains(t, b.String(), " 72788.25 ")
+	require.Contains(t, b.String(), " 42 ")
+}
+
+func TestJsonChartWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatJSON, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleChart()))
+	require.NoError(t, w.Write(&types.Chart{Ticker: "GME", Ohlc: []types.Ohlc{}}))
+	require.NoError(t, w.Flush())
+
+	var out []ChartRecord
+	require.NoError(t, json.Unmarshal(b.Bytes(), &out))
+	require.Equal(t, 2, len(out), "Should contain two charts")
+	require.Equal(t, "AAPL", out[0].Ticker)
+	require.Equal(t, "2021-04-01T16:00:03-04:00", out[0].Ohlc[0].Timestamp)
+	require.Equal(t, 124.18000030517578, out[0].Ohlc[0].High)
+	require.Equal(t, "GME", out[1].Ticker)
+	require.Empty(t, out[1].Ohlc)
+}
+
+func TestJsonChartWriterNoContent(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatJSON, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.Equal(t, "[]\n", b.String())
+}
+
+func TestNdjsonChartWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatNDJSON, &b)
+	require.NoError(t, err)
+	chart := sampleChart()
+	chart.Ohlc = append(chart.Ohlc, chart.Ohlc[0])
+	require.NoError(t, w.Write(chart))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Equal(t, 2, len(lines), "Should contain one line per point")
+	var record OhlcRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, NewOhlcRecord("AAPL", chart.Ohlc[0]), record)
+}
+
+func TestNdjsonChartWriterCrypto(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewChartWriter(FormatNDJSON, &b)
+	require.NoError(t, err)
+	chart := sampleChart()
+	require.NoError(t, w.Write(chart))
+	chart.Ohlc[0].QuoteVolume = 72788.25
+	chart.Ohlc[0].Trades = 42
+	require.NoError(t, w.Write(chart))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.NotContains(t, lines[0], "quote_volume", "Stock records must not have a quote volume")
+	require.Contains(t, lines[1], `"quote_volume":72788.25,"trades":42`)
+}
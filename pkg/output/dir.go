@@ -0,0 +1,506 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	FormatParquet string = "parquet"
+)
+
+const (
+	// Replace existing files
+	DirReplace string = "replace"
+	// Append the rows newer than the last row of existing files
+	DirAppend string = "append"
+	// Merge rows into existing files. Rows replace the existing
+	// rows with the same timestamp.
+	DirMerge string = "merge"
+)
+
+// fileCodec reads and writes all the records of one ticker file
+type fileCodec interface {
+	read(path string) (ChartRecord, error)
+	write(path string, chart ChartRecord) error
+}
+
+// DirWriter writes each chart to its own file '<dir>/<ticker>.<format>'.
+// Files are written to a temporary file first and renamed once complete
+// so that readers never see a partially written file.
+type DirWriter struct {
+	dir    string
+	format string
+	codec  fileCodec
+	mode   string
+}
+
+// NewDirWriter creates a ChartWriter that writes one file per ticker in dir.
+// The mode tells how the rows of existing files are kept: DirReplace,
+// DirAppend or DirMerge.
+func NewDirWriter(dir string, format string, mode string) (*DirWriter, error) {
+	switch mode {
+	case DirReplace, DirAppend, DirMerge:
+	default:
+		return nil, fmt.Errorf("Unknown write mode '%s'", mode)
+	}
+	var codec fileCodec
+	switch format {
+	case FormatCSV:
+		codec = csvCodec{}
+	case FormatJSON:
+		codec = jsonCodec{}
+	case FormatNDJSON:
+		codec = ndjsonCodec{}
+	case FormatParquet:
+		codec = parquetCodec{}
+	default:
+		return nil, fmt.Errorf("Output format '%s' cannot be written to files", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirWriter{
+		dir:    dir,
+		format: format,
+		codec:  codec,
+		mode:   mode,
+	}, nil
+}
+
+// fileName replaces the characters of the ticker that are not allowed in
+// file names, e.g. '/' or ':', by '_'
+func fileName(ticker string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("-_.^=@", r):
+			return r
+		}
+		return '_'
+	}, ticker)
+}
+
+// Path returns the name of the file holding the ticker data
+func (d *DirWriter) Path(ticker string) (string, error) {
+	name := fileName(ticker)
+	if strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("Invalid file name for ticker '%s'", ticker)
+	}
+	return filepath.Join(d.dir, fmt.Sprintf("%s.%s", name, d.format)), nil
+}
+
+func (d *DirWriter) Write(chart *types.Chart) error {
+	path, err := d.Path(chart.Ticker)
+	if err != nil {
+		return err
+	}
+	record := NewChartRecord(chart)
+	if d.mode != DirReplace {
+		existing, err := d.codec.read(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error reading '%s': %v", path, err)
+		}
+		if d.mode == DirAppend {
+			record, err = appendNewer(existing, record)
+		} else {
+			record, err = merge(existing, record)
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading '%s': %v", path, err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(d.dir, fmt.Sprintf(".%s.*.tmp", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	if err = d.codec.write(tmpName, record); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func (d *DirWriter) Flush() error {
+	return nil
+}
+
+// Read returns the records of the ticker file
+func (d *DirWriter) Read(ticker string) (ChartRecord, error) {
+	path, err := d.Path(ticker)
+	if err != nil {
+		return ChartRecord{}, err
+	}
+	return d.codec.read(path)
+}
+
+// appendNewer returns the existing records followed by the records
+// strictly newer than the last existing one
+func appendNewer(existing ChartRecord, chart ChartRecord) (ChartRecord, error) {
+	var err error
+	out := chart
+	out.Ohlc, err = appendNewerRows(existing.Ohlc, chart.Ohlc, func(r OhlcRecord) string { return r.Timestamp })
+	if err != nil {
+		return out, err
+	}
+	out.Dividends, err = appendNewerRows(existing.Dividends, chart.Dividends, func(r DividendRecord) string { return r.Timestamp })
+	if err != nil {
+		return out, err
+	}
+	out.Splits, err = appendNewerRows(existing.Splits, chart.Splits, func(r SplitRecord) string { return r.Timestamp })
+	return out, err
+}
+
+func appendNewerRows[T any](existing []T, records []T, timestamp func(T) string) ([]T, error) {
+	if len(existing) == 0 {
+		return records, nil
+	}
+	var last time.Time
+	for _, r := range existing {
+		t, err := time.Parse(time.RFC3339, timestamp(r))
+		if err != nil {
+			return nil, err
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	out := existing
+	for _, r := range records {
+		t, err := time.Parse(time.RFC3339, timestamp(r))
+		if err != nil {
+			return nil, err
+		}
+		if t.After(last) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// merge returns the existing and new records sorted by timestamp.
+// New records replace the existing records with the same timestamp.
+func merge(existing ChartRecord, chart ChartRecord) (ChartRecord, error) {
+	var err error
+	out := chart
+	out.Ohlc, err = mergeRows(existing.Ohlc, chart.Ohlc, func(r OhlcRecord) string { return r.Timestamp })
+	if err != nil {
+		return out, err
+	}
+	out.Dividends, err = mergeRows(existing.Dividends, chart.Dividends, func(r DividendRecord) string { return r.Timestamp })
+	if err != nil {
+		return out, err
+	}
+	out.Splits, err = mergeRows(existing.Splits, chart.Splits, func(r SplitRecord) string { return r.Timestamp })
+	return out, err
+}
+
+func mergeRows[T any](existing []T, records []T, timestamp func(T) string) ([]T, error) {
+	rows := make(map[int64]T)
+	for _, rs := range [][]T{existing, records} {
+		for _, r := range rs {
+			t, err := time.Parse(time.RFC3339, timestamp(r))
+			if err != nil {
+				return nil, err
+			}
+			rows[t.Unix()] = r
+		}
+	}
+	keys := make([]int64, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	out := make([]T, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, rows[k])
+	}
+	return out, nil
+}
+
+func writeFile(path string, encode func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = encode(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type csvCodec struct{}
+
+func (csvCodec) read(path string) (ChartRecord, error) {
+	chart := ChartRecord{}
+	f, err := os.Open(path)
+	if err != nil {
+		return chart, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return chart, err
+	}
+	chart.Ohlc = make([]OhlcRecord, 0)
+	if len(rows) == 0 {
+		return chart, nil
+	}
+	// Columns are read by name
+	columns := make(map[string]int)
+	for k, name := range rows[0] {
+		columns[name] = k
+	}
+	for _, name := range ohlcHeader {
+		if _, ok := columns[name]; !ok {
+			return chart, fmt.Errorf("Missing column '%s'", name)
+		}
+	}
+	for j, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			return chart, fmt.Errorf("Invalid number of columns on line %d", j+2)
+		}
+		r := OhlcRecord{
+			Ticker:    row[columns["ticker"]],
+			Timestamp: row[columns["timestamp"]],
+		}
+		floats := map[string]*float64{
+			"open":         &r.Open,
+			"high":         &r.High,
+			"low":          &r.Low,
+			"close":        &r.Close,
+			"adj_close":    &r.AdjClose,
+			"base_volume":  &r.BaseVolume,
+			"quote_volume": &r.QuoteVolume,
+		}
+		for name, v := range floats {
+			if *v, err = strconv.ParseFloat(row[columns[name]], 64); err != nil {
+				return chart, err
+			}
+		}
+		for name, v := range map[string]*int64{"volume": &r.Volume, "trades": &r.Trades} {
+			if *v, err = strconv.ParseInt(row[columns[name]], 10, 64); err != nil {
+				return chart, err
+			}
+		}
+		chart.Ticker = r.Ticker
+		chart.Ohlc = append(chart.Ohlc, r)
+	}
+	return chart, nil
+}
+
+func (csvCodec) write(path string, chart ChartRecord) error {
+	return writeFile(path, func(w io.Writer) error {
+		out := csv.NewWriter(w)
+		if err := out.Write(ohlcHeader); err != nil {
+			return err
+		}
+		for _, r := range chart.Ohlc {
+			if err := out.Write(r.strings()); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return out.Error()
+	})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) read(path string) (ChartRecord, error) {
+	chart := ChartRecord{}
+	f, err := os.Open(path)
+	if err != nil {
+		return chart, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&chart)
+	return chart, err
+}
+
+func (jsonCodec) write(path string, chart ChartRecord) error {
+	return writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(chart)
+	})
+}
+
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) read(path string) (ChartRecord, error) {
+	chart := ChartRecord{}
+	f, err := os.Open(path)
+	if err != nil {
+		return chart, err
+	}
+	defer f.Close()
+	chart.Ohlc = make([]OhlcRecord, 0)
+	dec := json.NewDecoder(f)
+	for {
+		var r OhlcRecord
+		err = dec.Decode(&r)
+		if err == io.EOF {
+			return chart, nil
+		}
+		if err != nil {
+			return chart, err
+		}
+		chart.Ticker = r.Ticker
+		chart.Ohlc = append(chart.Ohlc, r)
+	}
+}
+
+func (ndjsonCodec) write(path string, chart ChartRecord) error {
+	return writeFile(path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, r := range chart.Ohlc {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// parquetOhlc is the Parquet schema of ticker files.
+// Timestamps are stored as UTC milliseconds.
+type parquetOhlc struct {
+	Ticker      string  `parquet:"name=ticker, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Timestamp   int64   `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Open        float64 `parquet:"name=open, type=DOUBLE"`
+	High        float64 `parquet:"name=high, type=DOUBLE"`
+	Low         float64 `parquet:"name=low, type=DOUBLE"`
+	Close       float64 `parquet:"name=close, type=DOUBLE"`
+	AdjClose    float64 `parquet:"name=adj_close, type=DOUBLE"`
+	Volume      int64   `parquet:"name=volume, type=INT64"`
+	BaseVolume  float64 `parquet:"name=base_volume, type=DOUBLE"`
+	QuoteVolume float64 `parquet:"name=quote_volume, type=DOUBLE"`
+	Trades      int64   `parquet:"name=trades, type=INT64"`
+}
+
+type parquetCodec struct{}
+
+func (parquetCodec) read(path string) (ChartRecord, error) {
+	chart := ChartRecord{}
+	if _, err := os.Stat(path); err != nil {
+		return chart, err
+	}
+	f, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return chart, err
+	}
+	defer f.Close()
+	pr, err := reader.NewParquetReader(f, new(parquetOhlc), 1)
+	if err != nil {
+		return chart, err
+	}
+	defer pr.ReadStop()
+	rows := make([]parquetOhlc, pr.GetNumRows())
+	if err = pr.Read(&rows); err != nil {
+		return chart, err
+	}
+	chart.Ohlc = make([]OhlcRecord, 0, len(rows))
+	for _, row := range rows {
+		chart.Ticker = row.Ticker
+		chart.Ohlc = append(chart.Ohlc, OhlcRecord{
+			Ticker:      row.Ticker,
+			Timestamp:   time.UnixMilli(row.Timestamp).UTC().Format(time.RFC3339),
+			Open:        row.Open,
+			High:        row.High,
+			Low:         row.Low,
+			Close:       row.Close,
+			AdjClose:    row.AdjClose,
+			Volume:      row.Volume,
+			BaseVolume:  row.BaseVolume,
+			QuoteVolume: row.QuoteVolume,
+			Trades:      row.Trades,
+		})
+	}
+	return chart, nil
+}
+
+func (parquetCodec) write(path string, chart ChartRecord) error {
+	f, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		return err
+	}
+	pw, err := writer.NewParquetWriter(f, new(parquetOhlc), 1)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	for _, r := range chart.Ohlc {
+		t, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		row := parquetOhlc{
+			Ticker:      r.Ticker,
+			Timestamp:   t.UnixMilli(),
+			Open:        r.Open,
+			High:        r.High,
+			Low:         r.Low,
+			Close:       r.Close,
+			AdjClose:    r.AdjClose,
+			Volume:      r.Volume,
+			BaseVolume:  r.BaseVolume,
+			QuoteVolume: r.QuoteVolume,
+			Trades:      r.Trades,
+		}
+		if err = pw.Write(row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,181 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirWriterUnknownFormat(t *testing.T) {
+	_, err := NewDirWriter(t.TempDir(), FormatTable, DirReplace)
+	require.Error(t, err)
+}
+
+func TestDirWriterAppend(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON, FormatParquet} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewDirWriter(dir, format, DirAppend)
+			require.NoError(t, err)
+
+			first := sampleChart()
+			first.Ohlc[0].Timestamp = first.Ohlc[0].Timestamp.UTC()
+			require.NoError(t, w.Write(first))
+			require.NoError(t, w.Flush())
+
+			next := sampleChart()
+			next.Ohlc[0].Timestamp = next.Ohlc[0].Timestamp.UTC()
+			next.Ohlc = append(next.Ohlc, types.Ohlc{
+				Ticker:    "AAPL",
+				Timestamp: next.Ohlc[0].Timestamp.Add(24 * time.Hour),
+				Open:      123.0,
+				High:      125.5,
+				Low:       122.25,
+				Close:     125.0,
+				Volume:    1000,
+			})
+			require.NoError(t, w.Write(next))
+
+			path := filepath.Join(dir, "AAPL."+format)
+			actual, err := w.Path("AAPL")
+			require.NoError(t, err)
+			require.Equal(t, path, actual)
+			records, err := w.codec.read(path)
+			require.NoError(t, err)
+			require.Equal(t, NewChartRecord(next).Ohlc, records.Ohlc)
+
+			// No temporary file must be left behind
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(entries), "Should contain one file")
+		})
+	}
+}
+
+func TestDirWriterUnknownMode(t *testing.T) {
+	_, err := NewDirWriter(t.TempDir(), FormatCSV, "upsert")
+	require.Error(t, err)
+}
+
+func TestDirWriterMerge(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatParquet} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewDirWriter(dir, format, DirMerge)
+			require.NoError(t, err)
+
+			first := sampleChart()
+			first.Ohlc[0].Timestamp = first.Ohlc[0].Timestamp.UTC()
+			require.NoError(t, w.Write(first))
+
+			// The last bar is updated and a new bar is added
+			next := sampleChart()
+			next.Ohlc[0].Timestamp = next.Ohlc[0].Timestamp.UTC()
+			next.Ohlc[0].Close = 124.0
+			next.Ohlc = append(next.Ohlc, types.Ohlc{
+				Ticker:    "AAPL",
+				Timestamp: next.Ohlc[0].Timestamp.Add(24 * time.Hour),
+				Open:      123.0,
+				High:      125.5,
+				Low:       122.25,
+				Close:     125.0,
+				Volume:    1000,
+			})
+			require.NoError(t, w.Write(next))
+
+			records, err := w.Read("AAPL")
+			require.NoError(t, err)
+			require.Equal(t, NewChartRecord(next).Ohlc, records.Ohlc)
+		})
+	}
+}
+
+func TestDirWriterOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, FormatCSV, DirReplace)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleChart()))
+	require.NoError(t, w.Write(&types.Chart{Ticker: "AAPL", Ohlc: []types.Ohlc{}}))
+
+	records, err := w.codec.read(filepath.Join(dir, "AAPL.csv"))
+	require.NoError(t, err)
+	require.Empty(t, records.Ohlc)
+}
+
+func TestDirWriterPath(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, FormatCSV, DirReplace)
+	require.NoError(t, err)
+	tests := map[string]string{
+		"AAPL":     "AAPL.csv",
+		"BRK.B":    "BRK.B.csv",
+		"^GSPC":    "^GSPC.csv",
+		"EURUSD=X": "EURUSD=X.csv",
+		"BTC/USD":  "BTC_USD.csv",
+		"X:BTCUSD": "X_BTCUSD.csv",
+		"..\\AAPL": ".._AAPL.csv",
+	}
+	for ticker, name := range tests {
+		path, err := w.Path(ticker)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, name), path, "Path must be the same")
+	}
+	for _, ticker := range []string{"", ".", ".."} {
+		_, err := w.Path(ticker)
+		require.Error(t, err, "Dot-only names must be rejected")
+	}
+}
+
+func TestDirWriterAppendCsvMissingColumn(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, FormatCSV, DirAppend)
+	require.NoError(t, err)
+	previous := "ticker,timestamp,open,high,low,close,volume\n" +
+		"AAPL,2021-03-31T20:00:00Z,121.65,123.52,121.15,122.15,118323800\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "AAPL.csv"), []byte(previous), 0644))
+
+	err = w.Write(sampleChart())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Missing column 'adj_close'")
+}
+
+func TestDirWriterCrypto(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON, FormatParquet} {
+		t.Run(format, func(t *testing.T) {
+			w, err := NewDirWriter(t.TempDir(), format, DirReplace)
+			require.NoError(t, err)
+			chart := sampleChart()
+			chart.Ohlc[0].Timestamp = chart.Ohlc[0].Timestamp.UTC()
+			chart.Ohlc[0].Volume = 2
+			chart.Ohlc[0].BaseVolume = 1.5
+			chart.Ohlc[0].QuoteVolume = 72788.25
+			chart.Ohlc[0].Trades = 42
+			require.NoError(t, w.Write(chart))
+
+			records, err := w.Read("AAPL")
+			require.NoError(t, err)
+			require.Equal(t, NewChartRecord(chart).Ohlc, records.Ohlc)
+		})
+	}
+}
+
+func TestDirWriterMergeSorted(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, FormatJSON, DirMerge)
+	require.NoError(t, err)
+	chart := sampleChart()
+	first := chart.Ohlc[0]
+	first.Timestamp = first.Timestamp.UTC()
+	second := first
+	second.Timestamp = first.Timestamp.Add(24 * time.Hour)
+	chart.Ohlc = []types.Ohlc{second, first}
+	require.NoError(t, w.Write(chart))
+
+	records, err := w.Read("AAPL")
+	require.NoError(t, err)
+	require.Equal(t, []OhlcRecord{NewOhlcRecord("AAPL", first), NewOhlcRecord("AAPL", second)}, records.Ohlc, "Rows must be sorted")
+}
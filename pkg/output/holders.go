@@ -0,0 +1,335 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/finance/types"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FormatYAML string = "yaml"
+)
+
+const (
+	holderTypeInstitutional = "institutional"
+	holderTypeFund          = "fund"
+)
+
+// Holders groups the holders information of one ticker
+type Holders struct {
+	Ticker               string
+	Breakdown            *types.HoldersBreakdown
+	InstitutionalHolders *types.HoldersTable
+	FundHolders          *types.HoldersTable
+	// Error fetching the holders of the ticker. No information is set if set.
+	Err *types.TickerError
+}
+
+// HoldersWriter renders the holders information received from a provider.
+// Write is called once per ticker and Flush once all holders were written.
+type HoldersWriter interface {
+	Write(holders *Holders) error
+	Flush() error
+}
+
+// BreakdownRecord is the machine-readable representation of a types.HoldersBreakdown
+type BreakdownRecord struct {
+	PctSharesHeldbyAllInsider         float64 `json:"pct_shares_held_by_all_insider" yaml:"pct_shares_held_by_all_insider"`
+	PctSharesHeldbyInstitutions       float64 `json:"pct_shares_held_by_institutions" yaml:"pct_shares_held_by_institutions"`
+	PctFloatHeldbyInstitutions        float64 `json:"pct_float_held_by_institutions" yaml:"pct_float_held_by_institutions"`
+	NumberofInstitutionsHoldingShares int64   `json:"number_of_institutions_holding_shares" yaml:"number_of_institutions_holding_shares"`
+}
+
+// HolderRecord is the machine-readable representation of a types.HoldersRow
+type HolderRecord struct {
+	Holder       string  `json:"holder" yaml:"holder"`
+	Shares       int64   `json:"shares" yaml:"shares"`
+	DateReported string  `json:"date_reported" yaml:"date_reported"`
+	PctOut       float64 `json:"pct_out" yaml:"pct_out"`
+	Value        int64   `json:"value" yaml:"value"`
+}
+
+// HoldersRecord is the machine-readable representation of Holders
+type HoldersRecord struct {
+	Ticker               string          `json:"ticker" yaml:"ticker"`
+	Breakdown            BreakdownRecord `json:"breakdown" yaml:"breakdown"`
+	InstitutionalHolders []HolderRecord  `json:"institutional_holders" yaml:"institutional_holders"`
+	FundHolders          []HolderRecord  `json:"fund_holders" yaml:"fund_holders"`
+}
+
+var holdersHeader = []string{
+	"ticker",
+	"pct_shares_held_by_all_insider",
+	"pct_shares_held_by_institutions",
+	"pct_float_held_by_institutions",
+	"number_of_institutions_holding_shares",
+	"holder_type",
+	"holder",
+	"shares",
+	"date_reported",
+	"pct_out",
+	"value",
+}
+
+// NewHoldersWriter returns the HoldersWriter for the given output format
+func NewHoldersWriter(format string, w io.Writer) (HoldersWriter, error) {
+	switch format {
+	case FormatTable:
+		return newTableHoldersWriter(w), nil
+	case FormatCSV:
+		return &csvHoldersWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonHoldersWriter{w: w}, nil
+	case FormatYAML:
+		return &yamlHoldersWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format '%s'", format)
+	}
+}
+
+func newHolderRecords(table *types.HoldersTable) []HolderRecord {
+	records := make([]HolderRecord, 0)
+	if table == nil {
+		return records
+	}
+	for _, row := range table.Rows {
+		records = append(records, HolderRecord{
+			Holder:       row.Holder,
+			Shares:       row.Shares,
+			DateReported: row.DateReported.Format(dateFormat),
+			PctOut:       row.PctOut,
+			Value:        row.Value,
+		})
+	}
+	return records
+}
+
+// NewHoldersRecord converts holders to their machine-readable representation
+func NewHoldersRecord(holders *Holders) HoldersRecord {
+	record := HoldersRecord{
+		Ticker:               holders.Ticker,
+		InstitutionalHolders: newHolderRecords(holders.InstitutionalHolders),
+		FundHolders:          newHolderRecords(holders.FundHolders),
+	}
+	if b := holders.Breakdown; b != nil {
+		record.Breakdown = BreakdownRecord{
+			PctSharesHeldbyAllInsider:         b.PctSharesHeldbyAllInsider,
+			PctSharesHeldbyInstitutions:       b.PctSharesHeldbyInstitutions,
+			PctFloatHeldbyInstitutions:        b.PctFloatHeldbyInstitutions,
+			NumberofInstitutionsHoldingShares: b.NumberofInstitutionsHoldingShares,
+		}
+	}
+	return record
+}
+
+type tableHoldersWriter struct {
+	w              io.Writer
+	breakdownTable *tablewriter.Table
+}
+
+func newTableHoldersWriter(w io.Writer) *tableHoldersWriter {
+	breakdownTable := tablewriter.NewWriter(w)
+	breakdownTable.SetHeader([]string{"Name",
+		"% of Shares Held by All Insider",
+		"% of Shares Held by Institutions",
+		"% of Float Held by Institutions",
+		"Number of Institutions Holding Shares",
+	})
+	breakdownTable.SetCaption(true, "Major Holders Breakdown.")
+	return &tableHoldersWriter{
+		w:              w,
+		breakdownTable: breakdownTable,
+	}
+}
+
+func (t *tableHoldersWriter) renderTable(table *types.HoldersTable, caption string) {
+	if table == nil {
+		return
+	}
+	out := tablewriter.NewWriter(t.w)
+	out.SetHeader([]string{
+		"Holder",
+		"Shares",
+		"Date Reported",
+		"% Out",
+		"Value",
+	})
+	for _, row := range table.Rows {
+		out.Append([]string{
+			row.Holder,
+			fmt.Sprintf("%d", row.Shares),
+			row.DateReported.Format(dateFormat),
+			fmt.Sprintf("%.02f", row.PctOut),
+			fmt.Sprintf("%d", row.Value),
+		})
+	}
+	out.SetCaption(true, fmt.Sprintf("%s '%s'.", caption, table.Ticker))
+	out.Render() // Send output
+}
+
+func (t *tableHoldersWriter) Write(holders *Holders) error {
+	if b := holders.Breakdown; b != nil {
+		t.breakdownTable.Append([]string{
+			holders.Ticker,
+			fmt.Sprintf("%.02f", b.PctSharesHeldbyAllInsider),
+			fmt.Sprintf("%.02f", b.PctSharesHeldbyInstitutions),
+			fmt.Sprintf("%.02f", b.PctFloatHeldbyInstitutions),
+			fmt.Sprintf("%d", b.NumberofInstitutionsHoldingShares),
+		})
+	}
+	t.renderTable(holders.InstitutionalHolders, "Top Institutional Holders")
+	t.renderTable(holders.FundHolders, "Top Mutual Fund Holders")
+	return nil
+}
+
+func (t *tableHoldersWriter) Flush() error {
+	t.breakdownTable.Render() // Send output
+	return nil
+}
+
+// csvHoldersWriter writes one row per holder. The breakdown columns
+// are repeated on every row of the same ticker.
+type csvHoldersWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvHoldersWriter) writeHeader() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.w.Write(holdersHeader)
+}
+
+func (c *csvHoldersWriter) Write(holders *Holders) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	record := NewHoldersRecord(holders)
+	breakdown := []string{
+		record.Ticker,
+		formatFloat(record.Breakdown.PctSharesHeldbyAllInsider),
+		formatFloat(record.Breakdown.PctSharesHeldbyInstitutions),
+		formatFloat(record.Breakdown.PctFloatHeldbyInstitutions),
+		strconv.FormatInt(record.Breakdown.NumberofInstitutionsHoldingShares, 10),
+	}
+	rows := 0
+	write := func(holderType string, holders []HolderRecord) error {
+		for _, h := range holders {
+			row := append(append([]string{}, breakdown...),
+				holderType,
+				h.Holder,
+				strconv.FormatInt(h.Shares, 10),
+				h.DateReported,
+				formatFloat(h.PctOut),
+				strconv.FormatInt(h.Value, 10),
+			)
+			if err := c.w.Write(row); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	}
+	if err := write(holderTypeInstitutional, record.InstitutionalHolders); err != nil {
+		return err
+	}
+	if err := write(holderTypeFund, record.FundHolders); err != nil {
+		return err
+	}
+	if rows == 0 {
+		// Keep the breakdown of tickers without any holder
+		row := append(breakdown, "", "", "", "", "", "")
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvHoldersWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonHoldersWriter streams a JSON array with one element per ticker
+type jsonHoldersWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonHoldersWriter) Write(holders *Holders) error {
+	delim := ","
+	if j.count == 0 {
+		delim = "["
+	}
+	b, err := json.Marshal(NewHoldersRecord(holders))
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(j.w, "%s\n%s", delim, b); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+func (j *jsonHoldersWriter) Flush() error {
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+// yamlHoldersWriter streams a YAML sequence with one element per ticker
+type yamlHoldersWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (y *yamlHoldersWriter) Write(holders *Holders) error {
+	b, err := yaml.Marshal([]HoldersRecord{NewHoldersRecord(holders)})
+	if err != nil {
+		return err
+	}
+	if _, err = y.w.Write(b); err != nil {
+		return err
+	}
+	y.count++
+	return nil
+}
+
+func (y *yamlHoldersWriter) Flush() error {
+	if y.count == 0 {
+		_, err := fmt.Fprintln(y.w, "[]")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func sampleHolders() *Holders {
+	reported, _ := time.Parse(dateFormat, "2020-12-30")
+	return &Holders{
+		Ticker: "GME",
+		Breakdown: &types.HoldersBreakdown{
+			Ticker:                            "GME",
+			PctSharesHeldbyAllInsider:         22.29,
+			PctSharesHeldbyInstitutions:       110.64,
+			PctFloatHeldbyInstitutions:        142.38,
+			NumberofInstitutionsHoldingShares: 247,
+		},
+		InstitutionalHolders: &types.HoldersTable{
+			Ticker: "GME",
+			Rows: []types.HoldersRow{
+				{
+					Holder:       "Blackrock Inc.",
+					Shares:       9217335,
+					DateReported: reported,
+					PctOut:       13.19,
+					Value:        173654591,
+				},
+			},
+		},
+		FundHolders: &types.HoldersTable{
+			Ticker: "GME",
+			Rows:   []types.HoldersRow{},
+		},
+	}
+}
+
+func TestUnknownHoldersFormat(t *testing.T) {
+	_, err := NewHoldersWriter("ndjson", &bytes.Buffer{})
+	require.Error(t, err)
+}
+
+func TestCsvHoldersWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewHoldersWriter(FormatCSV, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleHolders()))
+	require.NoError(t, w.Flush())
+
+	expected := "ticker,pct_shares_held_by_all_insider,pct_shares_held_by_institutions,pct_float_held_by_institutions,number_of_institutions_holding_shares,holder_type,holder,shares,date_reported,pct_out,value\n" +
+		"GME,22.29,110.64,142.38,247,institutional,Blackrock Inc.,9217335,2020-12-30,13.19,173654591\n"
+	require.Equal(t, expected, b.String())
+}
+
+func TestHoldersWriterWithoutBreakdown(t *testing.T) {
+	holders := &Holders{Ticker: "GME"}
+	var b bytes.Buffer
+	w, err := NewHoldersWriter(FormatTable, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(holders))
+	require.NoError(t, w.Flush())
+	require.Contains(t, b.String(), "Major Holders Breakdown.")
+
+	b.Reset()
+	w, err = NewHoldersWriter(FormatCSV, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(holders))
+	require.NoError(t, w.Flush())
+	require.Contains(t, b.String(), "\nGME,0,0,0,0,,,,,,\n", "Ticker must be set")
+}
+
+func TestJsonHoldersWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewHoldersWriter(FormatJSON, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleHolders()))
+	require.NoError(t, w.Flush())
+
+	var out []HoldersRecord
+	require.NoError(t, json.Unmarshal(b.Bytes(), &out))
+	require.Equal(t, []HoldersRecord{NewHoldersRecord(sampleHolders())}, out)
+}
+
+func TestYamlHoldersWriter(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewHoldersWriter(FormatYAML, &b)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(sampleHolders()))
+	require.NoError(t, w.Write(sampleHolders()))
+	require.NoError(t, w.Flush())
+
+	var out []HoldersRecord
+	require.NoError(t, yaml.Unmarshal(b.Bytes(), &out))
+	require.Equal(t, 2, len(out), "Should contain two tickers")
+	require.Equal(t, NewHoldersRecord(sampleHolders()), out[0])
+}
@@ -0,0 +1,178 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// QuoteWriter renders the quotes received from a provider
+type QuoteWriter interface {
+	Write(quotes []types.Quote) error
+}
+
+// QuoteRecord is the machine-readable representation of a types.Quote
+type QuoteRecord struct {
+	Ticker        string  `json:"ticker"`
+	Timestamp     string  `json:"timestamp"`
+	Currency      string  `json:"currency"`
+	Price         float64 `json:"price"`
+	Bid           float64 `json:"bid"`
+	Ask           float64 `json:"ask"`
+	Open          float64 `json:"open"`
+	PreviousClose float64 `json:"previous_close"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	Volume        int64   `json:"volume"`
+	MarketState   string  `json:"market_state"`
+}
+
+var quoteHeader = []string{
+	"ticker",
+	"timestamp",
+	"currency",
+	"price",
+	"bid",
+	"ask",
+	"open",
+	"previous_close",
+	"change",
+	"change_percent",
+	"volume",
+	"market_state",
+}
+
+// NewQuoteWriter returns the QuoteWriter for the given output format
+func NewQuoteWriter(format string, w io.Writer) (QuoteWriter, error) {
+	switch format {
+	case FormatTable:
+		return &tableQuoteWriter{w: w}, nil
+	case FormatCSV:
+		return &csvQuoteWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonQuoteWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format '%s'", format)
+	}
+}
+
+// NewQuoteRecord converts a quote to its machine-readable representation
+func NewQuoteRecord(q types.Quote) QuoteRecord {
+	return QuoteRecord{
+		Ticker:        q.Ticker,
+		Timestamp:     q.Timestamp.Format(time.RFC3339),
+		Currency:      q.Currency,
+		Price:         q.Price,
+		Bid:           q.Bid,
+		Ask:           q.Ask,
+		Open:          q.Open,
+		PreviousClose: q.PreviousClose,
+		Change:        q.Change,
+		ChangePercent: q.ChangePercent,
+		Volume:        q.Volume,
+		MarketState:   q.MarketState,
+	}
+}
+
+type tableQuoteWriter struct {
+	w io.Writer
+}
+
+func (t *tableQuoteWriter) Write(quotes []types.Quote) error {
+	table := tablewriter.NewWriter(t.w)
+	table.SetHeader([]string{
+		"Ticker",
+		"Price",
+		"Bid",
+		"Ask",
+		"Change",
+		"Change %",
+		"Open",
+		"Previous Close",
+		"Volume",
+		"Market State",
+		"Time",
+	})
+	for _, q := range quotes {
+		table.Append([]string{
+			q.Ticker,
+			fmt.Sprintf("%.02f", q.Price),
+			fmt.Sprintf("%.02f", q.Bid),
+			fmt.Sprintf("%.02f", q.Ask),
+			fmt.Sprintf("%+.02f", q.Change),
+			fmt.Sprintf("%+.02f", q.ChangePercent),
+			fmt.Sprintf("%.02f", q.Open),
+			fmt.Sprintf("%.02f", q.PreviousClose),
+			fmt.Sprintf("%d", q.Volume),
+			q.MarketState,
+			formatDate(q.Timestamp),
+		})
+	}
+	table.Render() // Send output
+	return nil
+}
+
+type csvQuoteWriter struct {
+	w io.Writer
+}
+
+func (c *csvQuoteWriter) Write(quotes []types.Quote) error {
+	out := csv.NewWriter(c.w)
+	if err := out.Write(quoteHeader); err != nil {
+		return err
+	}
+	for _, q := range quotes {
+		r := NewQuoteRecord(q)
+		row := []string{
+			r.Ticker,
+			r.Timestamp,
+			r.Currency,
+			formatFloat(r.Price),
+			formatFloat(r.Bid),
+			formatFloat(r.Ask),
+			formatFloat(r.Open),
+			formatFloat(r.PreviousClose),
+			formatFloat(r.Change),
+			formatFloat(r.ChangePercent),
+			strconv.FormatInt(r.Volume, 10),
+			r.MarketState,
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+type jsonQuoteWriter struct {
+	w io.Writer
+}
+
+func (j *jsonQuoteWriter) Write(quotes []types.Quote) error {
+	records := make([]QuoteRecord, 0, len(quotes))
+	for _, q := range quotes {
+		records = append(records, NewQuoteRecord(q))
+	}
+	return json.NewEncoder(j.w).Encode(records)
+}
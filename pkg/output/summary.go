@@ -0,0 +1,136 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+const (
+	// Never fail because of ticker errors
+	FailOnNone string = "none"
+	// Fail if the data of any ticker could not be fetched
+	FailOnPartial string = "partial"
+	// Fail if the data of any ticker could not be fetched or is empty
+	FailOnAny string = "any"
+)
+
+// ValidFailOn returns true if the --fail-on policy is supported
+func ValidFailOn(policy string) bool {
+	switch policy {
+	case FailOnNone, FailOnPartial, FailOnAny:
+		return true
+	default:
+		return false
+	}
+}
+
+// Summary counts the tickers fetched during a run and their errors
+type Summary struct {
+	mu      sync.Mutex
+	tickers int
+	done    int
+	empty   []string
+	errors  []*types.TickerError
+}
+
+// NewSummary creates the summary of a run fetching the given number of tickers
+func NewSummary(tickers int) *Summary {
+	return &Summary{
+		tickers: tickers,
+		empty:   make([]string, 0),
+		errors:  make([]*types.TickerError, 0),
+	}
+}
+
+// Done records a ticker fetched with the given number of rows
+func (s *Summary) Done(ticker string, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done++
+	if rows == 0 {
+		s.empty = append(s.empty, ticker)
+	}
+}
+
+// Fail records the error of a ticker
+func (s *Summary) Fail(err *types.TickerError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, err)
+}
+
+// Errors returns the errors of the run
+func (s *Summary) Errors() []*types.TickerError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*types.TickerError{}, s.errors...)
+}
+
+// missing returns the number of tickers neither fetched nor failed
+func (s *Summary) missing() int {
+	missing := s.tickers - s.done - len(s.errors)
+	if missing < 0 {
+		return 0
+	}
+	return missing
+}
+
+// Write prints the summary of the run
+func (s *Summary) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintf(w, "Fetched %d of %d tickers: %d failed, %d empty, %d missing\n",
+		s.done, s.tickers, len(s.errors), len(s.empty), s.missing())
+	if err != nil {
+		return err
+	}
+	for _, e := range s.errors {
+		status := ""
+		if e.StatusCode != 0 {
+			status = fmt.Sprintf(", HTTP status %d", e.StatusCode)
+		}
+		if _, err = fmt.Fprintf(w, "  %s (%s%s): %v\n", e.Ticker, e.Provider, status, e.Err); err != nil {
+			return err
+		}
+	}
+	for _, ticker := range s.empty {
+		if _, err = fmt.Fprintf(w, "  %s: no data\n", ticker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns an error if the run failed according to the --fail-on policy
+func (s *Summary) Check(policy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failed := len(s.errors) + s.missing()
+	switch policy {
+	case FailOnNone:
+		return nil
+	case FailOnAny:
+		failed += len(s.empty)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tickers failed", failed, s.tickers)
+	}
+	return nil
+}
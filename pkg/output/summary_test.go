@@ -0,0 +1,41 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/regel/wsb/pkg/common"
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	summary := NewSummary(4)
+	summary.Done("AAPL", 10)
+	summary.Done("GME", 0)
+	summary.Fail(types.NewTickerError("UNKNOWN", "yahoo", &common.StatusError{StatusCode: 404}))
+
+	var b bytes.Buffer
+	require.NoError(t, summary.Write(&b))
+	expected := "Fetched 2 of 4 tickers: 1 failed, 1 empty, 1 missing\n" +
+		"  UNKNOWN (yahoo, HTTP status 404): Non-OK HTTP status: 404\n" +
+		"  GME: no data\n"
+	require.Equal(t, expected, b.String())
+
+	require.NoError(t, summary.Check(FailOnNone))
+	require.EqualError(t, summary.Check(FailOnPartial), "2 of 4 tickers failed")
+	require.EqualError(t, summary.Check(FailOnAny), "3 of 4 tickers failed")
+}
+
+func TestSummaryEmpty(t *testing.T) {
+	summary := NewSummary(1)
+	summary.Done("GME", 0)
+	require.NoError(t, summary.Check(FailOnPartial), "Empty data is not an error")
+	require.Error(t, summary.Check(FailOnAny))
+
+	summary = NewSummary(1)
+	summary.Fail(types.NewTickerError("GME", "iex", errors.New("timeout")))
+	require.Equal(t, 0, summary.Errors()[0].StatusCode, "Status must be unset")
+	require.Error(t, summary.Check(FailOnPartial))
+}
@@ -0,0 +1,126 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+// SymbolWriter renders the results of a symbol search
+type SymbolWriter interface {
+	Write(symbols []types.Symbol) error
+}
+
+// SymbolRecord is the machine-readable representation of a types.Symbol
+type SymbolRecord struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange"`
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+}
+
+var symbolHeader = []string{
+	"symbol",
+	"name",
+	"exchange",
+	"type",
+	"id",
+}
+
+// NewSymbolWriter returns the SymbolWriter for the given output format
+func NewSymbolWriter(format string, w io.Writer) (SymbolWriter, error) {
+	switch format {
+	case FormatTable:
+		return &tableSymbolWriter{w: w}, nil
+	case FormatCSV:
+		return &csvSymbolWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonSymbolWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format '%s'", format)
+	}
+}
+
+func (r SymbolRecord) strings() []string {
+	return []string{
+		r.Symbol,
+		r.Name,
+		r.Exchange,
+		r.Type,
+		r.Id,
+	}
+}
+
+// NewSymbolRecord converts a symbol to its machine-readable representation
+func NewSymbolRecord(s types.Symbol) SymbolRecord {
+	return SymbolRecord(s)
+}
+
+type tableSymbolWriter struct {
+	w io.Writer
+}
+
+func (t *tableSymbolWriter) Write(symbols []types.Symbol) error {
+	table := tablewriter.NewWriter(t.w)
+	table.SetHeader([]string{
+		"Symbol",
+		"Name",
+		"Exchange",
+		"Type",
+		"Id",
+	})
+	for _, s := range symbols {
+		table.Append(NewSymbolRecord(s).strings())
+	}
+	table.Render() // Send output
+	return nil
+}
+
+type csvSymbolWriter struct {
+	w io.Writer
+}
+
+func (c *csvSymbolWriter) Write(symbols []types.Symbol) error {
+	out := csv.NewWriter(c.w)
+	if err := out.Write(symbolHeader); err != nil {
+		return err
+	}
+	for _, s := range symbols {
+		if err := out.Write(NewSymbolRecord(s).strings()); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+type jsonSymbolWriter struct {
+	w io.Writer
+}
+
+func (j *jsonSymbolWriter) Write(symbols []types.Symbol) error {
+	records := make([]SymbolRecord, 0, len(symbols))
+	for _, s := range symbols {
+		records = append(records, NewSymbolRecord(s))
+	}
+	return json.NewEncoder(j.w).Encode(records)
+}
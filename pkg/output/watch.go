@@ -0,0 +1,114 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/regel/wsb/pkg/finance/types"
+)
+
+const (
+	// Move the cursor to the top left corner and clear the screen
+	ansiClearScreen = "\033[H\033[2J"
+)
+
+// WatchView renders a table of quotes refreshed in place with ANSI
+// escape codes. Prices are highlighted in green on an up tick and
+// in red on a down tick since the previous refresh.
+type WatchView struct {
+	w    io.Writer
+	last map[string]float64
+}
+
+func NewWatchView(w io.Writer) *WatchView {
+	return &WatchView{
+		w:    w,
+		last: make(map[string]float64),
+	}
+}
+
+// ChangeSinceOpen returns the change of the quote price since the open price in %
+func ChangeSinceOpen(q types.Quote) float64 {
+	if q.Open == 0 {
+		return 0
+	}
+	return (q.Price - q.Open) / q.Open * 100
+}
+
+func signColor(v float64) tablewriter.Colors {
+	switch {
+	case v > 0:
+		return tablewriter.Colors{tablewriter.FgGreenColor}
+	case v < 0:
+		return tablewriter.Colors{tablewriter.FgRedColor}
+	}
+	return tablewriter.Colors{}
+}
+
+// Render redraws the table with the given quotes. Errors are shown
+// below the table so that they are cleared by the next refresh.
+func (v *WatchView) Render(quotes []types.Quote, errs []error, at time.Time) error {
+	var b bytes.Buffer
+	table := tablewriter.NewWriter(&b)
+	table.SetHeader([]string{
+		"Ticker",
+		"Price",
+		"Change %",
+		"Open",
+		"Volume",
+		"Market State",
+		"Time",
+	})
+	for _, q := range quotes {
+		tick := 0.0
+		if last, ok := v.last[q.Ticker]; ok {
+			tick = q.Price - last
+		}
+		v.last[q.Ticker] = q.Price
+		change := ChangeSinceOpen(q)
+		// Quotes without open price, e.g. CoinGecko quotes, have no change
+		var open, changeCell string
+		if q.Open != 0 {
+			open = fmt.Sprintf("%.02f", q.Open)
+			changeCell = fmt.Sprintf("%+.02f", change)
+		}
+		table.Rich([]string{
+			q.Ticker,
+			fmt.Sprintf("%.02f", q.Price),
+			changeCell,
+			open,
+			fmt.Sprintf("%d", q.Volume),
+			q.MarketState,
+			formatDate(q.Timestamp),
+		}, []tablewriter.Colors{
+			{},
+			signColor(tick),
+			signColor(change),
+		})
+	}
+	table.SetCaption(true, fmt.Sprintf("Updated %s. Press Ctrl-C to exit.", at.Format(dateFormatLong)))
+	table.Render()
+	for _, err := range errs {
+		fmt.Fprintln(&b, err)
+	}
+
+	_, err := fmt.Fprint(v.w, ansiClearScreen, b.String())
+	return err
+}
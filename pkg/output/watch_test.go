@@ -0,0 +1,57 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchViewTicks(t *testing.T) {
+	var b bytes.Buffer
+	v := NewWatchView(&b)
+	q := types.Quote{
+		Ticker:    "AAPL",
+		Timestamp: time.Unix(1617307203, 0),
+		Price:     100,
+		Open:      100,
+	}
+	require.NoError(t, v.Render([]types.Quote{q}, nil, time.Now()))
+	require.True(t, strings.HasPrefix(b.String(), ansiClearScreen), "Screen must be cleared")
+	require.NotContains(t, b.String(), "\033[32m", "First refresh must not highlight")
+
+	b.Reset()
+	q.Price = 101
+	require.NoError(t, v.Render([]types.Quote{q}, nil, time.Now()))
+	require.Contains(t, b.String(), "\033[32m101.00", "Up tick must be green")
+	require.Contains(t, b.String(), "+1.00", "Change since open must be printed")
+
+	b.Reset()
+	q.Price = 99
+	require.NoError(t, v.Render([]types.Quote{q}, nil, time.Now()))
+	require.Contains(t, b.String(), "\033[31m99.00", "Down tick must be red")
+}
+
+func TestWatchViewErrors(t *testing.T) {
+	var b bytes.Buffer
+	v := NewWatchView(&b)
+	q := types.Quote{
+		Ticker:    "bitcoin",
+		Timestamp: time.Unix(1617307203, 0),
+		Price:     58000,
+	}
+	err := errors.New("Error fetching 'GME' data: timeout")
+	require.NoError(t, v.Render([]types.Quote{q}, []error{err}, time.Now()))
+	require.True(t, strings.HasPrefix(b.String(), ansiClearScreen), "Errors must be rendered after the screen is cleared")
+	require.True(t, strings.HasSuffix(b.String(), err.Error()+"\n"), "Errors must be rendered below the table")
+	require.NotContains(t, b.String(), "+0.00", "Change must be empty without open price")
+}
+
+func TestChangeSinceOpen(t *testing.T) {
+	require.InDelta(t, 10.0, ChangeSinceOpen(types.Quote{Price: 110, Open: 100}), 0.0001)
+	require.Equal(t, 0.0, ChangeSinceOpen(types.Quote{Price: 110}))
+}
@@ -0,0 +1,149 @@
+// Copyright The TB Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+)
+
+// Metadata describes the data stored for one ticker and the last fetch
+type Metadata struct {
+	Provider string    `json:"provider"`
+	Ticker   string    `json:"ticker"`
+	Interval string    `json:"interval"`
+	Format   string    `json:"format"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	Rows     int       `json:"rows"`
+	// Provider, time range and number of rows of the last fetch
+	Source      string    `json:"source"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	FetchedFrom time.Time `json:"fetched_from"`
+	FetchedTo   time.Time `json:"fetched_to"`
+	FetchedRows int       `json:"fetched_rows"`
+}
+
+// Store is a local time-series store of OHLC data. The data of each
+// ticker is stored in '<dir>/<provider>/<interval>/<ticker>.<format>'
+// next to its metadata file '<ticker>.<format>.meta.json'.
+type Store struct {
+	provider string
+	interval string
+	format   string
+	writer   *output.DirWriter
+}
+
+// NewStore opens the store of the provider and interval in dir
+func NewStore(dir string, provider string, interval string, format string) (*Store, error) {
+	writer, err := output.NewDirWriter(filepath.Join(dir, provider, interval), format, output.DirMerge)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		provider: provider,
+		interval: interval,
+		format:   format,
+		writer:   writer,
+	}, nil
+}
+
+// metadataPath returns the metadata file of the ticker. Each format has its
+// own metadata so that files of different formats are synced separately.
+func (s *Store) metadataPath(ticker string) (string, error) {
+	path, err := s.writer.Path(ticker)
+	if err != nil {
+		return "", err
+	}
+	return path + ".meta.json", nil
+}
+
+// Metadata returns the metadata of the ticker, or nil if the ticker was never synced
+func (s *Store) Metadata(ticker string) (*Metadata, error) {
+	path, err := s.metadataPath(ticker)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{}
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("Error reading '%s' metadata: %v", ticker, err)
+	}
+	return m, nil
+}
+
+// Write merges the chart fetched for the time range into the stored data
+// and returns the updated metadata. Bars already stored are replaced by the
+// fetched bars with the same timestamp.
+func (s *Store) Write(chart *types.Chart, from time.Time, to time.Time) (*Metadata, error) {
+	if err := s.writer.Write(chart); err != nil {
+		return nil, err
+	}
+	record, err := s.writer.Read(chart.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	m := Metadata{
+		Provider:    s.provider,
+		Ticker:      chart.Ticker,
+		Interval:    s.interval,
+		Format:      s.format,
+		Rows:        len(record.Ohlc),
+		Source:      chart.Provider,
+		FetchedAt:   time.Now().UTC(),
+		FetchedFrom: from.UTC(),
+		FetchedTo:   to.UTC(),
+		FetchedRows: len(chart.Ohlc),
+	}
+	if len(record.Ohlc) > 0 {
+		if m.First, err = time.Parse(time.RFC3339, record.Ohlc[0].Timestamp); err != nil {
+			return nil, err
+		}
+		if m.Last, err = time.Parse(time.RFC3339, record.Ohlc[len(record.Ohlc)-1].Timestamp); err != nil {
+			return nil, err
+		}
+		m.First = m.First.UTC()
+		m.Last = m.Last.UTC()
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.metadataPath(chart.Ticker)
+	if err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return &m, nil
+}
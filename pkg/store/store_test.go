@@ -0,0 +1,91 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/regel/wsb/pkg/finance/types"
+	"github.com/regel/wsb/pkg/output"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleChart(closes ...float64) *types.Chart {
+	chart := &types.Chart{Ticker: "AAPL", Ohlc: []types.Ohlc{}}
+	for j, c := range closes {
+		chart.Ohlc = append(chart.Ohlc, types.Ohlc{
+			Ticker:    "AAPL",
+			Timestamp: time.Date(2021, 4, 1+j, 0, 0, 0, 0, time.UTC),
+			Open:      c,
+			High:      c,
+			Low:       c,
+			Close:     c,
+			AdjClose:  c,
+			Volume:    1000,
+		})
+	}
+	return chart
+}
+
+func TestStoreSync(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir, "yahoo", "1d", output.FormatCSV)
+	require.NoError(t, err)
+
+	m, err := s.Metadata("AAPL")
+	require.NoError(t, err)
+	require.Nil(t, m, "Ticker was never synced")
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC)
+	_, err = s.Write(sampleChart(120, 121), from, to)
+	require.NoError(t, err)
+
+	// The last bar is fetched again with the new bars
+	last := time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC)
+	next := sampleChart(120, 122, 123)
+	next.Ohlc = next.Ohlc[1:]
+	_, err = s.Write(next, last, to.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	m, err = s.Metadata("AAPL")
+	require.NoError(t, err)
+	require.Equal(t, "yahoo", m.Provider)
+	require.Equal(t, "1d", m.Interval)
+	require.Equal(t, 3, m.Rows, "Overlapping bars must be deduplicated")
+	require.Equal(t, 2, m.FetchedRows)
+	require.True(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC).Equal(m.First))
+	require.True(t, time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC).Equal(m.Last))
+	require.True(t, last.Equal(m.FetchedFrom))
+
+	w, err := output.NewDirWriter(filepath.Join(dir, "yahoo", "1d"), output.FormatCSV, output.DirReplace)
+	require.NoError(t, err)
+	record, err := w.Read("AAPL")
+	require.NoError(t, err)
+	require.Equal(t, 122.0, record.Ohlc[1].Close, "Fetched bar must replace the stored bar")
+}
+
+func TestStoreFormats(t *testing.T) {
+	dir := t.TempDir()
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC)
+	csv, err := NewStore(dir, "yahoo", "1d", output.FormatCSV)
+	require.NoError(t, err)
+	_, err = csv.Write(sampleChart(120, 121, 122), from, to)
+	require.NoError(t, err)
+
+	parquet, err := NewStore(dir, "yahoo", "1d", output.FormatParquet)
+	require.NoError(t, err)
+	m, err := parquet.Metadata("AAPL")
+	require.NoError(t, err)
+	require.Nil(t, m, "Ticker was never synced in this format")
+
+	_, err = parquet.Write(sampleChart(120), from, to)
+	require.NoError(t, err)
+	m, err = csv.Metadata("AAPL")
+	require.NoError(t, err)
+	require.Equal(t, 3, m.Rows, "Formats must not share metadata")
+	m, err = parquet.Metadata("AAPL")
+	require.NoError(t, err)
+	require.Equal(t, 1, m.Rows, "Formats must not share metadata")
+}